@@ -0,0 +1,210 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+var Err_Bad_Capture = errors.New("not a capture file")
+var Err_Version = errors.New("unsupported capture version")
+var Err_Record_Len = errors.New("capture record exceeds the max length")
+
+// capture file is a file header followed by records, all integers are big endian:
+// file header: magic(4) | version(2)
+// record: direction(1) | unix nano(8) | fd(8) | header length(2) | body length(4) | header | body
+const (
+	file_magic        uint32 = 0x4b434150 // KCAP
+	file_version      uint16 = 1
+	file_header_len          = 6
+	record_header_len        = 23
+	default_max_record_len   = 1 << 20
+)
+
+type Direction byte
+
+const (
+	Direction_In  Direction = 1 // read from the peer
+	Direction_Out Direction = 2 // written to the peer
+)
+
+func (d Direction) String() string {
+	switch d {
+	case Direction_In:
+		return "in"
+	case Direction_Out:
+		return "out"
+	default:
+		return "unkown"
+	}
+}
+
+// Record is a decoded packet of a connection
+type Record struct {
+	Direction Direction
+	Time      time.Time
+	FD        uint64
+	Header    []byte
+	Body      []byte
+}
+
+// Writer write records to a capture file, it is safe for concurrent use
+type Writer struct {
+	w      *bufio.Writer
+	closer io.Closer
+	locker sync.Mutex
+}
+
+// Create the capture file of path
+func Create(path string) (*Writer, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := NewWriter(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	w.closer = file
+	return w, nil
+}
+
+func NewWriter(w io.Writer) (*Writer, error) {
+	writer := &Writer{w: bufio.NewWriter(w)}
+	var head [file_header_len]byte
+	binary.BigEndian.PutUint32(head[:], file_magic)
+	binary.BigEndian.PutUint16(head[4:], file_version)
+	if _, err := writer.w.Write(head[:]); err != nil {
+		return nil, err
+	}
+
+	return writer, nil
+}
+
+func (w *Writer) Write(r *Record) error {
+	var head [record_header_len]byte
+	head[0] = byte(r.Direction)
+	binary.BigEndian.PutUint64(head[1:], uint64(r.Time.UnixNano()))
+	binary.BigEndian.PutUint64(head[9:], r.FD)
+	binary.BigEndian.PutUint16(head[17:], uint16(len(r.Header)))
+	binary.BigEndian.PutUint32(head[19:], uint32(len(r.Body)))
+
+	w.locker.Lock()
+	defer w.locker.Unlock()
+	if _, err := w.w.Write(head[:]); err != nil {
+		return err
+	}
+	if _, err := w.w.Write(r.Header); err != nil {
+		return err
+	}
+	_, err := w.w.Write(r.Body)
+	return err
+}
+
+func (w *Writer) Flush() error {
+	w.locker.Lock()
+	defer w.locker.Unlock()
+	return w.w.Flush()
+}
+
+// Close flush the records and close the file created by Create
+func (w *Writer) Close() error {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if w.closer == nil {
+		return nil
+	}
+
+	return w.closer.Close()
+}
+
+// Reader read records from a capture file
+type Reader struct {
+	r      *bufio.Reader
+	closer io.Closer
+	maxLen int
+}
+
+// Open the capture file of path
+func Open(path string) (*Reader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	r.closer = file
+	return r, nil
+}
+
+func NewReader(r io.Reader) (*Reader, error) {
+	reader := &Reader{r: bufio.NewReader(r), maxLen: default_max_record_len}
+	var head [file_header_len]byte
+	if _, err := io.ReadFull(reader.r, head[:]); err != nil {
+		return nil, Err_Bad_Capture
+	}
+	if binary.BigEndian.Uint32(head[:]) != file_magic {
+		return nil, Err_Bad_Capture
+	}
+	if binary.BigEndian.Uint16(head[4:]) != file_version {
+		return nil, Err_Version
+	}
+
+	return reader, nil
+}
+
+// WithMaxLen the max length of the header and body of a record, the default is 1MB
+func (r *Reader) WithMaxLen(maxLen int) *Reader {
+	if maxLen > 0 {
+		r.maxLen = maxLen
+	}
+	return r
+}
+
+// Next read the next record, io.EOF at the end of the file,
+// Err_Record_Len when the lengths of the record exceed the max length
+func (r *Reader) Next() (*Record, error) {
+	var head [record_header_len]byte
+	if _, err := io.ReadFull(r.r, head[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, Err_Bad_Capture
+		}
+		return nil, err
+	}
+
+	record := &Record{Direction: Direction(head[0]), Time: time.Unix(0, int64(binary.BigEndian.Uint64(head[1:]))), FD: binary.BigEndian.Uint64(head[9:])}
+	headerLen, bodyLen := int(binary.BigEndian.Uint16(head[17:])), int64(binary.BigEndian.Uint32(head[19:]))
+	if int64(headerLen)+bodyLen > int64(r.maxLen) {
+		return nil, Err_Record_Len
+	}
+
+	buff := make([]byte, headerLen+int(bodyLen))
+	if _, err := io.ReadFull(r.r, buff); err != nil {
+		return nil, Err_Bad_Capture
+	}
+
+	record.Header = buff[:headerLen:headerLen]
+	record.Body = buff[headerLen:]
+	return record, nil
+}
+
+func (r *Reader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+
+	return r.closer.Close()
+}
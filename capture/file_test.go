@@ -0,0 +1,95 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// capture the file of the records
+func capture(t *testing.T, records ...*Record) []byte {
+	t.Helper()
+	var buff bytes.Buffer
+	w, err := NewWriter(&buff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range records {
+		if err := w.Write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buff.Bytes()
+}
+
+func record(fd uint64, body string) *Record {
+	return &Record{Direction: Direction_In, Time: time.Unix(0, int64(fd)), FD: fd, Header: []byte{0, 0, 0, byte(len(body))}, Body: []byte(body)}
+}
+
+func TestReaderRoundTrip(t *testing.T) {
+	r, err := NewReader(bytes.NewReader(capture(t, record(7, "one"), record(8, "two"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expect := range []*Record{record(7, "one"), record(8, "two")} {
+		got, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.FD != expect.FD || got.Direction != expect.Direction || !got.Time.Equal(expect.Time) ||
+			!bytes.Equal(got.Header, expect.Header) || !bytes.Equal(got.Body, expect.Body) {
+			t.Fatal(got)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Fatal(err)
+	}
+}
+
+func TestReaderCorrupt(t *testing.T) {
+	valid := capture(t, record(7, "one"))
+	oversize := bytes.Clone(valid)
+	binary.BigEndian.PutUint32(oversize[file_header_len+19:], 0xffffffff)
+	bigHeader := bytes.Clone(valid)
+	binary.BigEndian.PutUint16(bigHeader[file_header_len+17:], 0xffff)
+	version := bytes.Clone(valid)
+	binary.BigEndian.PutUint16(version[4:], file_version+1)
+
+	tests := []struct {
+		name   string
+		data   []byte
+		maxLen int
+		open   error
+		next   error
+	}{
+		{"empty", nil, 0, Err_Bad_Capture, nil},
+		{"bad magic", []byte("KCAQ\x00\x01"), 0, Err_Bad_Capture, nil},
+		{"version", version, 0, Err_Version, nil},
+		{"truncated record header", valid[:file_header_len+10], 0, nil, Err_Bad_Capture},
+		{"truncated body", valid[:len(valid)-1], 0, nil, Err_Bad_Capture},
+		{"oversize body", oversize, 0, nil, Err_Record_Len},
+		{"oversize header", bigHeader, 1024, nil, Err_Record_Len},
+		{"max len", valid, 6, nil, Err_Record_Len},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewReader(bytes.NewReader(tt.data))
+			if !errors.Is(err, tt.open) {
+				t.Fatal(err)
+			}
+			if err != nil {
+				return
+			}
+			if _, err := r.WithMaxLen(tt.maxLen).Next(); !errors.Is(err, tt.next) {
+				t.Fatal(err)
+			}
+		})
+	}
+}
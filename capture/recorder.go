@@ -0,0 +1,42 @@
+package capture
+
+import (
+	"time"
+
+	"github.com/kovey/network-go/v2/connection"
+	"github.com/kovey/network-go/v2/logger"
+)
+
+// Recorder is a connection.ITap writes the packets of the connections to a capture file
+type Recorder struct {
+	w      *Writer
+	filter func(*connection.Connection) bool
+}
+
+func NewRecorder(w *Writer) *Recorder {
+	return &Recorder{w: w}
+}
+
+// WithFilter only the connections filter returns true are recorded
+func (r *Recorder) WithFilter(filter func(*connection.Connection) bool) *Recorder {
+	r.filter = filter
+	return r
+}
+
+func (r *Recorder) record(dir Direction, c *connection.Connection, p *connection.Packet) {
+	if r.filter != nil && !r.filter(c) {
+		return
+	}
+
+	if err := r.w.Write(&Record{Direction: dir, Time: time.Now(), FD: c.FD(), Header: p.Header, Body: p.Body}); err != nil {
+		c.Logger().Erro("capture packet failure", logger.Err(err))
+	}
+}
+
+func (r *Recorder) OnRead(c *connection.Connection, p *connection.Packet) {
+	r.record(Direction_In, c, p)
+}
+
+func (r *Recorder) OnWrite(c *connection.Connection, p *connection.Packet) {
+	r.record(Direction_Out, c, p)
+}
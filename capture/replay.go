@@ -0,0 +1,205 @@
+package capture
+
+import (
+	"context"
+	"io"
+	"net"
+	"time"
+
+	"github.com/kovey/network-go/v2/client"
+	"github.com/kovey/network-go/v2/connection"
+	"github.com/kovey/network-go/v2/server"
+)
+
+// Replayer feed the records of a capture to a handler or send them through a client
+type Replayer struct {
+	r      *Reader
+	scale  float64
+	dir    Direction
+	fd     uint64
+	header *connection.Header
+	tap    connection.ITap
+	first  time.Time
+	start  time.Time
+}
+
+// NewReplayer replay the inbound records of all connections without waiting
+func NewReplayer(r *Reader) *Replayer {
+	return &Replayer{r: r, dir: Direction_In, header: connection.NewHeader()}
+}
+
+// WithScale the gaps between the records are multiplied by scale, 1 is real time, 0 replays without waiting
+func (p *Replayer) WithScale(scale float64) *Replayer {
+	p.scale = scale
+	return p
+}
+
+// WithDirection only the records of dir are replayed, the default is Direction_In
+func (p *Replayer) WithDirection(dir Direction) *Replayer {
+	p.dir = dir
+	return p
+}
+
+// WithFD only the records of the connection fd are replayed, 0 is all connections
+func (p *Replayer) WithFD(fd uint64) *Replayer {
+	p.fd = fd
+	return p
+}
+
+// WithHeader the header of the connections created by ToHandler
+func (p *Replayer) WithHeader(header *connection.Header) *Replayer {
+	p.header = header
+	return p
+}
+
+// WithTap observe the packets written by the handler in ToHandler, e.g. a Recorder to compare with the capture
+func (p *Replayer) WithTap(tap connection.ITap) *Replayer {
+	p.tap = tap
+	return p
+}
+
+// next the next record to replay, it waits for the scaled gap to the first record
+func (p *Replayer) next(ctx context.Context) (*Record, error) {
+	for {
+		record, err := p.r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if record.Direction != p.dir || (p.fd != 0 && record.FD != p.fd) {
+			continue
+		}
+
+		if p.first.IsZero() {
+			p.first = record.Time
+			p.start = time.Now()
+		}
+		if p.scale > 0 {
+			at := p.start.Add(time.Duration(float64(record.Time.Sub(p.first)) * p.scale))
+			if wait := time.Until(at); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return nil, ctx.Err()
+				case <-timer.C:
+				}
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		return record, nil
+	}
+}
+
+// ToHandler feed the records into handler, a connection is created for every fd of the capture,
+// the packets written by the handler are discarded unless a tap is set
+func (p *Replayer) ToHandler(ctx context.Context, handler server.IHandler) error {
+	conns := make(map[uint64]*connection.Connection)
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+			handler.Close(conn)
+		}
+	}()
+
+	for {
+		record, err := p.next(ctx)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		conn, ok := conns[record.FD]
+		if !ok {
+			conn = connection.NewConnectionBy(p.header, record.FD, discard{})
+			if p.tap != nil {
+				conn.WithTap(p.tap)
+			}
+			conns[record.FD] = conn
+			if err := handler.Connect(conn); err != nil {
+				return err
+			}
+		}
+
+		sc := server.NewContext(conn.Context())
+		sc.Conn = conn
+		sc.Data = (&connection.Packet{Header: record.Header, Body: record.Body}).Bind(p.header)
+		err = handler.Receive(sc)
+		sc.Drop()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// ToClient send the records of one connection through the connected client,
+// it is the connection set by WithFD or the first connection of the capture
+func (p *Replayer) ToClient(ctx context.Context, cli *client.Client) error {
+	for {
+		record, err := p.next(ctx)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if p.fd == 0 {
+			p.fd = record.FD
+		}
+
+		data := make([]byte, 0, len(record.Header)+len(record.Body))
+		data = append(append(data, record.Header...), record.Body...)
+		if err := cli.Send(data); err != nil {
+			return err
+		}
+	}
+}
+
+type replayAddr struct{}
+
+func (replayAddr) Network() string {
+	return "replay"
+}
+
+func (replayAddr) String() string {
+	return "replay"
+}
+
+// discard is the net connection of the replayed connections
+type discard struct{}
+
+func (discard) Read([]byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (discard) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (discard) Close() error {
+	return nil
+}
+
+func (discard) LocalAddr() net.Addr {
+	return replayAddr{}
+}
+
+func (discard) RemoteAddr() net.Addr {
+	return replayAddr{}
+}
+
+func (discard) SetDeadline(time.Time) error {
+	return nil
+}
+
+func (discard) SetReadDeadline(time.Time) error {
+	return nil
+}
+
+func (discard) SetWriteDeadline(time.Time) error {
+	return nil
+}
@@ -0,0 +1,148 @@
+package capture
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kovey/network-go/v2/client"
+	"github.com/kovey/network-go/v2/connection"
+	"github.com/kovey/network-go/v2/server"
+)
+
+// echoHandler record the received bodies and echo them with the prefix re:
+type echoHandler struct {
+	got    []string
+	locker sync.Mutex
+}
+
+func (h *echoHandler) Connect(*connection.Connection) error { return nil }
+func (h *echoHandler) Receive(ctx *server.Context) error {
+	h.locker.Lock()
+	h.got = append(h.got, string(ctx.Data.Body))
+	h.locker.Unlock()
+	return ctx.Conn.Write(connection.NewPacket([]byte("re:"+string(ctx.Data.Body)), ctx.Conn.Header()).Bytes())
+}
+func (h *echoHandler) Close(*connection.Connection) error { return nil }
+
+func (h *echoHandler) bodies() []string {
+	h.locker.Lock()
+	defer h.locker.Unlock()
+	return append([]string(nil), h.got...)
+}
+
+func packets(header *connection.Header, fd uint64, bodies ...string) []*Record {
+	records := make([]*Record, len(bodies))
+	for i, body := range bodies {
+		p := connection.NewPacket([]byte(body), header)
+		data := p.Bytes()
+		records[i] = &Record{Direction: Direction_In, Time: time.Unix(0, int64(i)*int64(time.Millisecond)), FD: fd,
+			Header: data[:header.HeaderLen()], Body: data[header.HeaderLen():]}
+	}
+	return records
+}
+
+func TestRecorder(t *testing.T) {
+	var buff bytes.Buffer
+	w, _ := NewWriter(&buff)
+	a, b := net.Pipe()
+	defer b.Close()
+	ca := connection.NewConnection(7, a).WithTap(NewRecorder(w))
+	cb := connection.NewConnection(8, b)
+	go cb.Write(connection.NewPacket([]byte("one"), cb.Header()).Bytes())
+	go cb.Read()
+	p, err := ca.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ca.Write(connection.NewPacket(p.Body, ca.Header()).Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	r, _ := NewReader(bytes.NewReader(buff.Bytes()))
+	for _, dir := range []Direction{Direction_In, Direction_Out} {
+		record, err := r.Next()
+		if err != nil || record.Direction != dir || record.FD != 7 || string(record.Body) != "one" {
+			t.Fatal(record, err)
+		}
+	}
+}
+
+func TestReplayToHandler(t *testing.T) {
+	header := connection.NewHeader()
+	records := append(packets(header, 7, "one", "two"), packets(header, 8, "three")...)
+	var out bytes.Buffer
+	ow, _ := NewWriter(&out)
+	r, _ := NewReader(bytes.NewReader(capture(t, records...)))
+	h := &echoHandler{}
+	if err := NewReplayer(r).WithTap(NewRecorder(ow)).ToHandler(context.Background(), h); err != nil {
+		t.Fatal(err)
+	}
+	if got := h.bodies(); len(got) != 3 || got[2] != "three" {
+		t.Fatal(got)
+	}
+
+	ow.Flush()
+	or, _ := NewReader(bytes.NewReader(out.Bytes()))
+	if o, err := or.Next(); err != nil || string(o.Body) != "re:one" || o.Direction != Direction_Out {
+		t.Fatal(o, err)
+	}
+}
+
+func TestReplayToClient(t *testing.T) {
+	header := connection.NewHeader()
+	records := packets(header, 7, "a1")
+	records = append(records, packets(header, 8, "b1")...)
+	records = append(records, packets(header, 7, "a2")...)
+
+	tests := []struct {
+		name   string
+		fd     uint64
+		expect []string
+	}{
+		{"first connection", 0, []string{"a1", "a2"}},
+		{"fd", 8, []string{"b1"}},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &echoHandler{}
+			s := server.NewServer("capture", i+1).WithService(server.NewMemoryService(10)).WithHandler(h)
+			ready := make(chan struct{})
+			s.OnSuccess = func(*server.Server) { close(ready) }
+			go s.ListenAndServ()
+			<-ready
+			defer s.Shutdown()
+
+			tcp := client.NewMemory()
+			cli := client.NewClient().WithService(tcp)
+			if err := cli.Dial("capture", i+1); err != nil {
+				t.Fatal(err)
+			}
+			go func() {
+				for {
+					if _, err := tcp.Connection().Read(); err != nil {
+						return
+					}
+				}
+			}()
+			defer tcp.Connection().Close()
+
+			r, _ := NewReader(bytes.NewReader(capture(t, records...)))
+			if err := NewReplayer(r).WithFD(tt.fd).ToClient(context.Background(), cli); err != nil {
+				t.Fatal(err)
+			}
+			for j := 0; j < 100 && len(h.bodies()) < len(tt.expect); j++ {
+				time.Sleep(10 * time.Millisecond)
+			}
+			time.Sleep(20 * time.Millisecond)
+			if got := h.bodies(); len(got) != len(tt.expect) || got[0] != tt.expect[0] || got[len(got)-1] != tt.expect[len(tt.expect)-1] {
+				t.Fatal(got)
+			}
+		})
+	}
+}
@@ -1,13 +1,17 @@
 package client
 
 import (
+	"context"
+	"fmt"
 	"io"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/kovey/debug-go/debug"
 	"github.com/kovey/debug-go/run"
 	"github.com/kovey/network-go/v2/connection"
+	"github.com/kovey/network-go/v2/logger"
+	"github.com/kovey/network-go/v2/resume"
 )
 
 type IClient interface {
@@ -15,6 +19,11 @@ type IClient interface {
 	Connection() *connection.Connection
 }
 
+// IContextClient is implemented by the service supports dial with context
+type IContextClient interface {
+	DialContext(ctx context.Context, host string, port int) error
+}
+
 type IHandler interface {
 	Receive(*connection.Packet, *Client) error
 	Idle(*Client) error
@@ -22,6 +31,12 @@ type IHandler interface {
 	Shutdown()
 }
 
+// ICloseHandler is implemented by the handler wants the reason the connection closed,
+// errors.Is(reason, connection.Err_EOF) tells the kind of the reason
+type ICloseHandler interface {
+	Closed(c *Client, reason error)
+}
+
 type Client struct {
 	cli        IClient
 	handler    IHandler
@@ -30,15 +45,32 @@ type Client struct {
 	ticker     *time.Ticker
 	host       string
 	port       int
-	isShutdown bool
+	isShutdown atomic.Bool
+	pushes     *dedup
+	resume     bool
+	token      string
+	locker     sync.Mutex
+	logger     logger.ILogger
 }
 
 func NewClient() *Client {
-	return &Client{wait: sync.WaitGroup{}, shutdown: make(chan bool, 1), ticker: time.NewTicker(10 * time.Second), isShutdown: false}
+	return &Client{wait: sync.WaitGroup{}, shutdown: make(chan bool, 1), ticker: time.NewTicker(10 * time.Second)}
 }
 
 func (c *Client) WithService(cli IClient) *Client {
 	c.cli = cli
+	if c.logger != nil {
+		cli.Connection().WithLogger(c.logger)
+	}
+	return c
+}
+
+// WithLogger the logger of the client and its connection
+func (c *Client) WithLogger(l logger.ILogger) *Client {
+	c.logger = l
+	if c.cli != nil {
+		c.cli.Connection().WithLogger(l)
+	}
 	return c
 }
 
@@ -47,22 +79,128 @@ func (c *Client) WithHandler(handler IHandler) *Client {
 	return c
 }
 
+func (c *Client) Connection() *connection.Connection {
+	return c.cli.Connection()
+}
+
 func (c *Client) Dial(host string, port int) error {
+	if err := c.checkKind(); err != nil {
+		return err
+	}
+
+	c.host = host
+	c.port = port
+	return c.cli.Dial(host, port)
+}
+
+// checkKind reject the push and resume when the header of the connection has no kind field
+func (c *Client) checkKind() error {
+	if c.cli.Connection().Header().HasKind() {
+		return nil
+	}
+	if c.pushes != nil {
+		return fmt.Errorf("%w: push", connection.Err_No_Kind)
+	}
+	if c.resume {
+		return fmt.Errorf("%w: resume", connection.Err_No_Kind)
+	}
+
+	return nil
+}
+
+// DialContext dial until the deadline of ctx or ctx is cancelled,
+// ctx is ignored when the service is not implements IContextClient
+func (c *Client) DialContext(ctx context.Context, host string, port int) error {
+	if err := c.checkKind(); err != nil {
+		return err
+	}
+
 	c.host = host
 	c.port = port
+	if cli, ok := c.cli.(IContextClient); ok {
+		return cli.DialContext(ctx, host, port)
+	}
+
 	return c.cli.Dial(host, port)
 }
 
+// ListenContext listen until ctx is cancelled, then the client is shutdown
+func (c *Client) ListenContext(ctx context.Context) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Shutdown()
+		case <-done:
+		}
+	}()
+
+	c.Listen()
+}
+
+// WithResume keep the session token issued by the server and resume it by Redial,
+// the header of the connection must have the kind field
+func (c *Client) WithResume() *Client {
+	c.resume = true
+	return c
+}
+
+// Redial dial again and resume the session issued by the server,
+// the resume frame is the first packet, the authenticate packets are sent after it
 func (c *Client) Redial() error {
-	return c.cli.Dial(c.host, c.port)
+	if err := c.cli.Dial(c.host, c.port); err != nil {
+		return err
+	}
+
+	if token := c.SessionToken(); c.resume && token != "" {
+		conn := c.cli.Connection()
+		return conn.WriteFrame(connection.Kind_Resume, resume.Resume(token))
+	}
+
+	return nil
+}
+
+// SessionToken the resumable session token issued by the server
+func (c *Client) SessionToken() string {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	return c.token
+}
+
+// handleResume consume the resume frames, the token of the issue frame is kept
+func (c *Client) handleResume(packet *connection.Packet) bool {
+	t, token, ok := resume.Decode(packet)
+	if !ok {
+		return false
+	}
+	if t != resume.Type_Issue {
+		return true
+	}
+
+	c.locker.Lock()
+	c.token = token
+	c.locker.Unlock()
+	return true
 }
 
 func (c *Client) handlerPacket(packet *connection.Packet) {
 	defer func() {
 		run.Panic(recover())
 	}()
+	if c.resume && c.handleResume(packet) {
+		return
+	}
+
+	if c.pushes != nil {
+		var ok bool
+		if packet, ok = c.handlePush(packet); !ok {
+			return
+		}
+	}
+
 	if err := c.handler.Receive(packet, c); err != nil {
-		debug.Erro("connection[%d] on receive failure, error: %s", c.cli.Connection().FD(), err)
+		c.cli.Connection().Logger().Erro("on receive failure", logger.Err(err))
 	}
 }
 
@@ -74,15 +212,19 @@ func (c *Client) Listen() {
 	go c.handlerIdle()
 
 	for {
-		if c.isShutdown {
+		if c.isShutdown.Load() {
 			break
 		}
 
 		pbuf, err := c.cli.Connection().Read()
-		if c.isShutdown {
+		if c.isShutdown.Load() {
 			break
 		}
 
+		if err != nil {
+			c.closeWith(err)
+		}
+
 		if err == io.EOF {
 			if !c.handler.Try(c) {
 				c.shutdown <- true
@@ -95,7 +237,7 @@ func (c *Client) Listen() {
 		if err != nil {
 			if !c.handler.Try(c) {
 				c.shutdown <- true
-				debug.Erro("connection[%d] read data failure, error: %s", c.cli.Connection().FD(), err)
+				c.cli.Connection().Logger().Erro("read data failure", logger.Err(err))
 				break
 			}
 
@@ -103,7 +245,7 @@ func (c *Client) Listen() {
 		}
 
 		if c == nil || c.handler == nil {
-			debug.Erro("client is nil or handler is nil")
+			logger.Default().Erro("client is nil or handler is nil")
 			break
 		}
 
@@ -123,27 +265,38 @@ func (c *Client) handlerIdle() {
 			return
 		case <-c.ticker.C:
 			if err := c.handler.Idle(c); err != nil {
-				debug.Erro("Idle failure, error: %s", err)
+				c.cli.Connection().Logger().Erro("idle failure", logger.Err(err))
 			}
 		}
 	}
 }
 
+// closeWith close the connection for reason and tell the handler when it is not closed yet
+func (c *Client) closeWith(reason error) {
+	conn := c.cli.Connection()
+	if err := conn.CloseWith(reason); err != nil {
+		return
+	}
+
+	if handler, ok := c.handler.(ICloseHandler); ok {
+		handler.Closed(c, conn.CloseReason())
+	}
+}
+
 func (c *Client) Close() {
 	c.handler.Shutdown()
-	c.cli.Connection().Close()
+	c.closeWith(connection.Err_Shutdown)
 	c.ticker.Stop()
 	c.wait.Wait()
 }
 
 func (c *Client) Shutdown() {
-	if c.isShutdown {
+	if c.isShutdown.Swap(true) {
 		return
 	}
 
 	c.shutdown <- true
-	c.isShutdown = true
-	c.cli.Connection().Close()
+	c.closeWith(connection.Err_Shutdown)
 }
 
 func (c *Client) Send(data []byte) error {
@@ -0,0 +1,63 @@
+package client
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/kovey/network-go/v2/connection"
+	"github.com/kovey/network-go/v2/resume"
+)
+
+type recordHandler struct {
+	packets []*connection.Packet
+}
+
+func (h *recordHandler) Receive(p *connection.Packet, c *Client) error {
+	h.packets = append(h.packets, p)
+	return nil
+}
+func (h *recordHandler) Idle(*Client) error { return nil }
+func (h *recordHandler) Try(*Client) bool   { return false }
+func (h *recordHandler) Shutdown()          {}
+
+func TestHandleResume(t *testing.T) {
+	tests := []struct {
+		name    string
+		resume  bool
+		token   string
+		handled int
+	}{
+		{"enabled", true, "token", 0},
+		{"disabled", false, "", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &recordHandler{}
+			tcp := NewTcp().WithHeaderLen(5).WithKind(4)
+			c := NewClient().WithService(tcp).WithHandler(h)
+			if tt.resume {
+				c.WithResume()
+			}
+
+			issue, _ := connection.EncodeFrame(connection.Kind_Resume, resume.Issue("token"), tcp.Connection().Header())
+			c.handlerPacket(issue)
+			if c.SessionToken() != tt.token || len(h.packets) != tt.handled {
+				t.Fatal(c.SessionToken(), len(h.packets))
+			}
+
+			// the application packet is not a resume frame
+			c.handlerPacket(connection.NewPacket(resume.Issue("other"), tcp.Connection().Header()))
+			if c.SessionToken() != tt.token || len(h.packets) != tt.handled+1 {
+				t.Fatal(c.SessionToken(), len(h.packets))
+			}
+		})
+	}
+}
+
+func TestResumeNoKind(t *testing.T) {
+	c := NewClient().WithService(NewMemory()).WithResume()
+	if err := c.Dial("resume", 1); !errors.Is(err, connection.Err_No_Kind) {
+		t.Fatal(err)
+	}
+}
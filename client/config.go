@@ -0,0 +1,120 @@
+package client
+
+import (
+	"github.com/kovey/network-go/v2/config"
+)
+
+// Config is the config of Client, it is loaded by LoadConfig and applied by NewClientFrom
+type Config struct {
+	Host        string             `yaml:"host" json:"host"`
+	Port        int                `yaml:"port" json:"port"`
+	Framing     config.Framing     `yaml:"framing" json:"framing"`
+	Limits      config.Limits      `yaml:"limits" json:"limits"`
+	Timeouts    config.Timeouts    `yaml:"timeouts" json:"timeouts"`
+	DialTimeout config.Duration    `yaml:"dial_timeout" json:"dial_timeout"` // the dial and handshake fail after it, 0 is disabled
+	Secure      config.Secure      `yaml:"secure" json:"secure"`
+	TLS         config.TLS         `yaml:"tls" json:"tls"`
+	Negotiation config.Negotiation `yaml:"negotiation" json:"negotiation"`
+}
+
+// DefaultConfig is the same as the defaults of NewTcp
+func DefaultConfig() Config {
+	return Config{Host: "127.0.0.1", Port: 9910, Framing: config.DefaultFraming(), Limits: config.DefaultLimits()}
+}
+
+// LoadConfig load the defaults, then the file of path when it is not empty, then the environment
+// variables start with envPrefix when it is not empty, e.g. NETWORK_FRAMING_HEADER_LEN
+func LoadConfig(path, envPrefix string) (Config, error) {
+	cfg := DefaultConfig()
+	if path != "" {
+		if err := config.Load(path, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+	if envPrefix != "" {
+		if err := config.Env(envPrefix, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+
+	return cfg, cfg.Validate()
+}
+
+func (c Config) Validate() error {
+	if c.Host == "" {
+		return config.Invalid("host", "is required")
+	}
+	if c.Port <= 0 || c.Port > 65535 {
+		return config.Invalid("port", "must be in [1, 65535], %d given", c.Port)
+	}
+	if err := c.Framing.Validate(); err != nil {
+		return config.Prefix("framing", err)
+	}
+	if err := c.Limits.Validate(c.Framing.HeaderLen); err != nil {
+		return config.Prefix("limits", err)
+	}
+	if err := c.Timeouts.Validate(); err != nil {
+		return config.Prefix("timeouts", err)
+	}
+	if c.DialTimeout < 0 {
+		return config.Invalid("dial_timeout", "must not be negative, %s given", c.DialTimeout.Std())
+	}
+	if err := c.Secure.Validate(); err != nil {
+		return config.Prefix("secure", err)
+	}
+	if err := c.TLS.Validate(false); err != nil {
+		return config.Prefix("tls", err)
+	}
+	if err := c.Negotiation.Validate(); err != nil {
+		return config.Prefix("negotiation", err)
+	}
+
+	return nil
+}
+
+// NewTcpFrom validate the config and create the Tcp of it
+func NewTcpFrom(c Config) (*Tcp, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := c.TLS.Client()
+	if err != nil {
+		return nil, err
+	}
+
+	l, _ := c.Framing.LenType()
+	e, _ := c.Framing.ByteOrder()
+	t := NewTcp().WithHeaderLen(c.Framing.HeaderLen).WithBodyLenOffset(c.Framing.BodyLenOffset).WithBodyLenType(l).WithEndian(e)
+	t.WithMaxLen(c.Limits.MaxLen).WithReadBuffLen(c.Limits.ReadBuffLen).WithShrinkAfter(c.Limits.ShrinkAfter.Std()).WithZeroCopy(c.Limits.ZeroCopy)
+	t.WithReadTimeout(c.Timeouts.Read.Std()).WithWriteTimeout(c.Timeouts.Write.Std()).WithHandshakeTimeout(c.Timeouts.Handshake.Std())
+	t.WithDialTimeout(c.DialTimeout.Std()).WithTLS(tlsConfig).WithSchema(c.Framing.Schema())
+	t.WithKind(c.Framing.Kind.KindOffset())
+	if ct, _ := c.Framing.Checksum.ChecksumType(); ct != 0 {
+		action, _ := c.Framing.Checksum.ChecksumAction()
+		t.WithChecksum(ct, c.Framing.Checksum.Offset).WithChecksumAction(action, nil)
+	}
+	if suite, ok := c.Secure.Suite(); ok {
+		t.WithPSK([]byte(c.Secure.PSK)).WithSecure(suite, c.Secure.RotateEvery)
+	}
+	if len(c.Negotiation.Versions) > 0 {
+		supported, required, _ := c.Negotiation.Flags()
+		t.WithVersions(c.Negotiation.Versions...).WithFeatures(supported, required)
+	}
+
+	return t, nil
+}
+
+// NewClientFrom validate the config and create the client with the Tcp of it, the address of
+// the config is used by Redial, the handler is set by WithHandler
+func NewClientFrom(c Config) (*Client, error) {
+	t, err := NewTcpFrom(c)
+	if err != nil {
+		return nil, err
+	}
+
+	cli := NewClient().WithService(t)
+	cli.host = c.Host
+	cli.port = c.Port
+	return cli, nil
+}
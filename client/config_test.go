@@ -0,0 +1,35 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "client.json")
+	json := `{"host":"localhost","port":9920,"dial_timeout":"2s","framing":{"header_len":6,"body_len_offset":2,"body_len_type":"uint16","endian":"little","kind":{"enable":true,"offset":0}}}`
+	if err := os.WriteFile(path, []byte(json), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TCLI_PORT", "9921")
+	t.Setenv("TCLI_TLS_ENABLE", "true")
+
+	c, err := LoadConfig(path, "tcli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Port != 9921 || c.DialTimeout.Std() != 2*time.Second || !c.TLS.Enable || c.Limits.MaxLen != 8192 {
+		t.Fatalf("%+v", c)
+	}
+
+	cli, err := NewClientFrom(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := cli.Connection().Header()
+	if cli.host != "localhost" || cli.port != 9921 || header.HeaderLen() != 6 || header.BodyLenOffset() != 2 || !header.HasKind() {
+		t.Fatal(cli.host, cli.port, header)
+	}
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"sync"
+
+	"github.com/kovey/network-go/v2/connection"
+	"github.com/kovey/network-go/v2/logger"
+	"github.com/kovey/network-go/v2/push"
+)
+
+// dedup remember the ids of the latest pushed messages
+type dedup struct {
+	seen   map[uint64]struct{}
+	ring   []uint64
+	pos    int
+	locker sync.Mutex
+}
+
+func newDedup(size int) *dedup {
+	return &dedup{seen: make(map[uint64]struct{}, size), ring: make([]uint64, size)}
+}
+
+// add return false when the id is already seen
+func (d *dedup) add(id uint64) bool {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+	if _, ok := d.seen[id]; ok {
+		return false
+	}
+
+	if old := d.ring[d.pos]; old != 0 {
+		delete(d.seen, old)
+	}
+	d.ring[d.pos] = id
+	d.pos = (d.pos + 1) % len(d.ring)
+	d.seen[id] = struct{}{}
+	return true
+}
+
+// WithPush ack the messages pushed by the server outbox and drop the redelivered duplicates,
+// the ids of the latest size messages are remembered, the header of the connection must have the kind field
+func (c *Client) WithPush(size int) *Client {
+	if size <= 0 {
+		size = 1024
+	}
+	c.pushes = newDedup(size)
+	return c
+}
+
+// handlePush return the packet with the pushed payload as body, ok is false when the packet is a duplicate
+func (c *Client) handlePush(packet *connection.Packet) (*connection.Packet, bool) {
+	t, id, payload, ok := push.Decode(packet)
+	if !ok || t != push.Type_Message {
+		return packet, true
+	}
+
+	conn := c.cli.Connection()
+	if err := conn.WriteFrame(connection.Kind_Push, push.Ack(id)); err != nil {
+		conn.Logger().Erro("ack message failure", logger.F("id", id), logger.Err(err))
+	}
+
+	if !c.pushes.add(id) {
+		return nil, false
+	}
+
+	packet.Body = payload
+	return packet, true
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/kovey/network-go/v2/connection"
+	"github.com/kovey/network-go/v2/push"
+)
+
+func TestDedup(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int
+		ids    []uint64
+		expect []bool
+	}{
+		{"new", 4, []uint64{1, 2, 3}, []bool{true, true, true}},
+		{"duplicate", 4, []uint64{1, 2, 1}, []bool{true, true, false}},
+		{"evicted", 2, []uint64{1, 2, 3, 1}, []bool{true, true, true, true}},
+		{"epoch", 4, []uint64{1<<32 | 1, 2<<32 | 1}, []bool{true, true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newDedup(tt.size)
+			for i, id := range tt.ids {
+				if d.add(id) != tt.expect[i] {
+					t.Fatal(i, id)
+				}
+			}
+		})
+	}
+}
+
+func TestHandlePush(t *testing.T) {
+	a, b := net.Pipe()
+	defer b.Close()
+	tcp := NewTcp().WithHeaderLen(5).WithKind(4)
+	tcp.Connection().WithConn(a)
+	c := NewClient().WithService(tcp).WithPush(16)
+	peer := connection.NewConnection(2, b).WithHeaderLen(5).WithKind(4)
+	acks := make(chan uint64, 4)
+	go func() {
+		for {
+			p, err := peer.Read()
+			if err != nil {
+				return
+			}
+			if typ, id, _, ok := push.Decode(p); ok && typ == push.Type_Ack {
+				acks <- id
+			}
+		}
+	}()
+
+	message, _ := connection.EncodeFrame(connection.Kind_Push, push.Message(7, []byte("hello")), tcp.Connection().Header())
+	if p, ok := c.handlePush(message); !ok || string(p.Body) != "hello" {
+		t.Fatal(p, ok)
+	}
+	message, _ = connection.EncodeFrame(connection.Kind_Push, push.Message(7, []byte("hello")), tcp.Connection().Header())
+	if _, ok := c.handlePush(message); ok {
+		t.Fatal("duplicate is delivered")
+	}
+	for i := 0; i < 2; i++ {
+		if id := <-acks; id != 7 {
+			t.Fatal(id)
+		}
+	}
+
+	// the application packet is not a push frame
+	data := connection.NewPacket(push.Message(8, nil), tcp.Connection().Header())
+	if p, ok := c.handlePush(data); !ok || p != data {
+		t.Fatal(p, ok)
+	}
+}
+
+func TestPushNoKind(t *testing.T) {
+	c := NewClient().WithService(NewMemory()).WithPush(0)
+	if err := c.Dial("push", 1); !errors.Is(err, connection.Err_No_Kind) {
+		t.Fatal(err)
+	}
+}
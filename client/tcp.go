@@ -1,31 +1,122 @@
 package client
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/binary"
 	"fmt"
 	"net"
+	"time"
 
 	"github.com/kovey/network-go/v2/connection"
+	"github.com/kovey/network-go/v2/logger"
+	"github.com/kovey/network-go/v2/memnet"
 )
 
 type Tcp struct {
-	conn *connection.Connection
+	conn        *connection.Connection
+	dial        func(ctx context.Context, address string) (net.Conn, error)
+	tls         *tls.Config
+	dialTimeout time.Duration
+	versions    []uint16
+	features    connection.Feature
+	required    connection.Feature
+	psk         []byte
 }
 
 func NewTcp() *Tcp {
 	return &Tcp{conn: connection.NewConnection(1, nil)}
 }
 
+// NewMemory dial the server.NewMemoryService in the same process, it is for tests
+func NewMemory() *Tcp {
+	return &Tcp{conn: connection.NewConnection(1, nil), dial: memnet.Dial}
+}
+
 func (t *Tcp) Dial(host string, port int) error {
-	conn, err := net.Dial("tcp", fmt.Sprintf("%s:%d", host, port))
+	return t.DialContext(context.Background(), host, port)
+}
+
+// DialContext dial and handshake until the deadline of ctx or ctx is cancelled
+func (t *Tcp) DialContext(ctx context.Context, host string, port int) error {
+	if err := t.conn.Validate(); err != nil {
+		return err
+	}
+
+	if t.dialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.dialTimeout)
+		defer cancel()
+	}
+
+	dial := t.dial
+	if dial == nil {
+		var dialer net.Dialer
+		dial = func(ctx context.Context, address string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "tcp", address)
+		}
+	}
+
+	conn, err := dial(ctx, fmt.Sprintf("%s:%d", host, port))
 	if err != nil {
 		return err
 	}
 
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+
+	if t.tls != nil {
+		cfg := t.tls
+		if cfg.ServerName == "" && !cfg.InsecureSkipVerify {
+			cfg = cfg.Clone()
+			cfg.ServerName = host
+		}
+		tc := tls.Client(conn, cfg)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		conn = tc
+	}
+
 	t.conn.WithConn(conn)
+	if err := t.conn.Handshake(); err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+
+	if !stop() {
+		conn.Close()
+		return ctx.Err()
+	}
+
+	conn.SetDeadline(time.Time{})
 	return nil
 }
 
+// WithTLS dial with tls, the server name is the dialed host when it is empty
+func (t *Tcp) WithTLS(cfg *tls.Config) *Tcp {
+	t.tls = cfg
+	return t
+}
+
+// WithDialTimeout the dial and handshake fail after timeout
+func (t *Tcp) WithDialTimeout(timeout time.Duration) *Tcp {
+	t.dialTimeout = timeout
+	return t
+}
+
 func (t *Tcp) Connection() *connection.Connection {
 	return t.conn
 }
@@ -45,6 +136,51 @@ func (t *Tcp) WithMaxLen(maxLen int) *Tcp {
 	return t
 }
 
+// WithReadBuffLen the read buffer starts with length and grows up to maxLen when a larger packet arrives
+func (t *Tcp) WithReadBuffLen(length int) *Tcp {
+	t.conn.WithReadBuffLen(length)
+	return t
+}
+
+// WithShrinkAfter the grown read buffer shrinks back when no data arrives in idle
+func (t *Tcp) WithShrinkAfter(idle time.Duration) *Tcp {
+	t.conn.WithShrinkAfter(idle)
+	return t
+}
+
+func (t *Tcp) WithZeroCopy(threshold int) *Tcp {
+	t.conn.WithZeroCopy(threshold)
+	return t
+}
+
+// WithReadTimeout the read fails with connection.Err_Read_Timeout when no data arrives in timeout
+func (t *Tcp) WithReadTimeout(timeout time.Duration) *Tcp {
+	t.conn.WithReadTimeout(timeout)
+	return t
+}
+
+func (t *Tcp) WithWriteTimeout(timeout time.Duration) *Tcp {
+	t.conn.WithWriteTimeout(timeout)
+	return t
+}
+
+func (t *Tcp) WithHandshakeTimeout(timeout time.Duration) *Tcp {
+	t.conn.WithHandshakeTimeout(timeout)
+	return t
+}
+
+// WithLogger the logger of the connection, the connection samples its records when it is a logger.Sampler
+func (t *Tcp) WithLogger(l logger.ILogger) *Tcp {
+	t.conn.WithLogger(l)
+	return t
+}
+
+// WithTap observe the packets of the connection, e.g. capture.Recorder
+func (t *Tcp) WithTap(tap connection.ITap) *Tcp {
+	t.conn.WithTap(tap)
+	return t
+}
+
 func (t *Tcp) WithHeaderLen(length int) *Tcp {
 	t.conn.WithHeaderLen(length)
 	return t
@@ -54,3 +190,66 @@ func (t *Tcp) WithBodyLenOffset(offset int) *Tcp {
 	t.conn.WithBodyLenOffset(offset)
 	return t
 }
+
+// WithSchema the named fields of the header, Packet.Field decodes them
+func (t *Tcp) WithSchema(schema *connection.Schema) *Tcp {
+	t.conn.WithSchema(schema)
+	return t
+}
+
+// WithChecksum the checksum of the body is written at offset of the header and verified by the read
+func (t *Tcp) WithChecksum(ct connection.ChecksumType, offset int) *Tcp {
+	t.conn.WithChecksum(ct, offset)
+	return t
+}
+
+// WithKind the byte at offset of the header tells the stream, mux, push and resume frames from the application packets
+func (t *Tcp) WithKind(offset int) *Tcp {
+	t.conn.WithKind(offset)
+	return t
+}
+
+// WithChecksumAction what the read does with the packet whose checksum mismatches, handler is used by connection.Checksum_Handler
+func (t *Tcp) WithChecksumAction(action connection.ChecksumAction, handler connection.ChecksumHandler) *Tcp {
+	t.conn.WithChecksumAction(action, handler)
+	return t
+}
+
+// WithVersions negotiate the protocol version with the server before the secure handshake,
+// the server chooses the highest version supported by both
+func (t *Tcp) WithVersions(versions ...uint16) *Tcp {
+	t.versions = versions
+	return t.negotiator()
+}
+
+// WithFeatures the features negotiated with the versions, the dial fails when the server does not support all the required
+func (t *Tcp) WithFeatures(supported, required connection.Feature) *Tcp {
+	t.features = supported
+	t.required = required
+	return t.negotiator()
+}
+
+func (t *Tcp) negotiator() *Tcp {
+	if len(t.versions) == 0 {
+		t.conn.WithNegotiator(nil)
+		return t
+	}
+
+	t.conn.WithNegotiator(connection.NewNegotiator(true, t.versions...).WithFeatures(t.features, t.required))
+	return t
+}
+
+// WithSecure encrypt packet body after an ECDH handshake, the key is rotated every rotateEvery packets
+func (t *Tcp) WithSecure(suite connection.CipherSuite, rotateEvery uint64) *Tcp {
+	t.conn.WithSecure(connection.NewSecure(suite, true).WithRotateEvery(rotateEvery).WithPSK(t.psk))
+	return t
+}
+
+// WithPSK the pre-shared key of the secure channel authenticates the handshake, the server must have the same key
+func (t *Tcp) WithPSK(psk []byte) *Tcp {
+	t.psk = psk
+	if s := t.conn.Secure(); s != nil {
+		s.WithPSK(psk)
+	}
+	return t
+}
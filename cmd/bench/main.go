@@ -0,0 +1,234 @@
+// Command bench connect many clients to a server and send packets of size bytes at rate packets/s per connection,
+// the first 8 bytes of the body are the send time, the latency is measured when the server echoes the body.
+//
+//	go run ./cmd/bench -host 127.0.0.1 -port 9910 -conns 1000 -rate 50 -size 256 -duration 30s
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kovey/network-go/v2/client"
+	"github.com/kovey/network-go/v2/connection"
+)
+
+type config struct {
+	host      string
+	port      int
+	conns     int
+	duration  time.Duration
+	rate      float64
+	size      int
+	lenType   connection.LenType
+	endian    binary.ByteOrder
+	headerLen int
+	offset    int
+	maxLen    int
+	reconnect bool
+	samples   int
+}
+
+var lenTypes = map[string]connection.LenType{
+	"int8": connection.Len_Type_Int8, "int16": connection.Len_Type_Int16, "int32": connection.Len_Type_Int32, "int64": connection.Len_Type_Int64,
+	"uint8": connection.Len_Type_UInt8, "uint16": connection.Len_Type_UInt16, "uint32": connection.Len_Type_UInt32, "uint64": connection.Len_Type_UInt64,
+}
+
+func parse() (*config, error) {
+	c := &config{}
+	var lenType, endian string
+	flag.StringVar(&c.host, "host", "127.0.0.1", "server host")
+	flag.IntVar(&c.port, "port", 9910, "server port")
+	flag.IntVar(&c.conns, "conns", 100, "number of connections")
+	flag.DurationVar(&c.duration, "duration", 10*time.Second, "duration of the benchmark")
+	flag.Float64Var(&c.rate, "rate", 10, "packets per second of every connection, 0 sends as fast as possible")
+	flag.IntVar(&c.size, "size", 128, "body length of the packets, at least 8 and fits the len-type and max-len")
+	flag.StringVar(&lenType, "len-type", "int32", "type of the body length: int8, int16, int32, int64, uint8, uint16, uint32, uint64")
+	flag.StringVar(&endian, "endian", "big", "byte order of the body length: big or little")
+	flag.IntVar(&c.headerLen, "header-len", 4, "header length")
+	flag.IntVar(&c.offset, "offset", 0, "offset of the body length in the header")
+	flag.IntVar(&c.maxLen, "max-len", 8192, "max packet length")
+	flag.BoolVar(&c.reconnect, "reconnect", true, "reconnect when the connection is closed")
+	flag.IntVar(&c.samples, "samples", 100000, "max latency samples kept")
+	flag.Parse()
+
+	var ok bool
+	if c.lenType, ok = lenTypes[strings.ToLower(lenType)]; !ok {
+		return nil, fmt.Errorf("unkown len-type: %s", lenType)
+	}
+	switch strings.ToLower(endian) {
+	case "big":
+		c.endian = binary.BigEndian
+	case "little":
+		c.endian = binary.LittleEndian
+	default:
+		return nil, fmt.Errorf("unkown endian: %s", endian)
+	}
+	if c.size < 8 {
+		return nil, fmt.Errorf("size must be at least 8")
+	}
+	if c.conns <= 0 {
+		return nil, fmt.Errorf("conns must be positive")
+	}
+	conn := c.tcp().Connection()
+	if err := conn.Validate(); err != nil {
+		return nil, err
+	}
+	if max := conn.MaxBodyLen(); c.size > max {
+		return nil, fmt.Errorf("size must be at most %d by max-len, header-len and len-type", max)
+	}
+
+	return c, nil
+}
+
+func (c *config) tcp() *client.Tcp {
+	return client.NewTcp().WithBodyLenType(c.lenType).WithEndian(c.endian).WithHeaderLen(c.headerLen).WithBodyLenOffset(c.offset).WithMaxLen(c.maxLen)
+}
+
+// handler measure the latency of the echoed packets and reconnect the closed connection
+type handler struct {
+	c     *config
+	stats *stats
+	ctx   context.Context
+}
+
+func (h *handler) Receive(packet *connection.Packet, _ *client.Client) error {
+	h.stats.received.Add(1)
+	h.stats.bytesRecv.Add(uint64(len(packet.Header) + len(packet.Body)))
+	if len(packet.Body) >= 8 {
+		if sent := int64(binary.BigEndian.Uint64(packet.Body)); sent > 0 {
+			h.stats.observe(time.Duration(time.Now().UnixNano() - sent))
+		}
+	}
+	return nil
+}
+
+func (h *handler) Idle(*client.Client) error {
+	return nil
+}
+
+func (h *handler) Try(cli *client.Client) bool {
+	h.stats.connected.Add(-1)
+	if !h.c.reconnect {
+		return false
+	}
+
+	for h.ctx.Err() == nil {
+		if err := cli.Dial(h.c.host, h.c.port); err == nil {
+			h.stats.reconnects.Add(1)
+			h.stats.connected.Add(1)
+			return true
+		}
+
+		h.stats.dialErrors.Add(1)
+		select {
+		case <-h.ctx.Done():
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	return false
+}
+
+func (h *handler) Shutdown() {
+}
+
+func worker(ctx context.Context, c *config, s *stats, wait *sync.WaitGroup) {
+	defer wait.Done()
+	tcp := c.tcp()
+	cli := client.NewClient().WithService(tcp).WithHandler(&handler{c: c, stats: s, ctx: ctx})
+	if err := cli.Dial(c.host, c.port); err != nil {
+		s.dialErrors.Add(1)
+		return
+	}
+	s.connected.Add(1)
+	go cli.Listen()
+	defer cli.Shutdown()
+
+	body := make([]byte, c.size)
+	for i := 8; i < len(body); i++ {
+		body[i] = byte(i)
+	}
+
+	var interval time.Duration
+	if c.rate > 0 {
+		interval = time.Duration(float64(time.Second) / c.rate)
+	}
+	// spread the first packets of the connections over the interval
+	next := time.Now().Add(time.Duration(rand.Int64N(int64(interval) + 1)))
+	for {
+		if interval > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Until(next)):
+			}
+			next = next.Add(interval)
+		} else if ctx.Err() != nil {
+			return
+		}
+
+		binary.BigEndian.PutUint64(body, uint64(time.Now().UnixNano()))
+		packet, err := connection.EncodePacket(body, tcp.Connection().Header())
+		if err != nil {
+			s.sendErrors.Add(1)
+			return
+		}
+		data := packet.Bytes()
+		if err := cli.Send(data); err != nil {
+			s.sendErrors.Add(1)
+			if interval == 0 {
+				time.Sleep(10 * time.Millisecond)
+			}
+			continue
+		}
+
+		s.sent.Add(1)
+		s.bytesSent.Add(uint64(len(data)))
+	}
+}
+
+func main() {
+	c, err := parse()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	ctx, cancelRun := context.WithTimeout(ctx, c.duration)
+	defer cancelRun()
+
+	s := newStats(c.samples)
+	start := time.Now()
+	var wait sync.WaitGroup
+	for i := 0; i < c.conns; i++ {
+		wait.Add(1)
+		go worker(ctx, c, s, &wait)
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	var lastSent, lastRecv uint64
+	for running := true; running; {
+		select {
+		case <-ctx.Done():
+			running = false
+		case <-ticker.C:
+			lastSent, lastRecv = s.progress(os.Stdout, time.Since(start), lastSent, lastRecv, time.Second)
+		}
+	}
+
+	elapsed := time.Since(start)
+	wait.Wait()
+	s.report(os.Stdout, elapsed)
+}
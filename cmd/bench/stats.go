@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// stats is shared by all workers, the latencies are the round trip of the echoed packets
+type stats struct {
+	sent        atomic.Uint64
+	received    atomic.Uint64
+	bytesSent   atomic.Uint64
+	bytesRecv   atomic.Uint64
+	sendErrors  atomic.Uint64
+	dialErrors  atomic.Uint64
+	reconnects  atomic.Uint64
+	connected   atomic.Int64
+	locker      sync.Mutex
+	latencies   []time.Duration
+	maxSamples  int
+	sampleCount uint64
+}
+
+func newStats(maxSamples int) *stats {
+	return &stats{maxSamples: maxSamples, latencies: make([]time.Duration, 0, 1024)}
+}
+
+// observe keep at most maxSamples latencies by reservoir sampling
+func (s *stats) observe(latency time.Duration) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.sampleCount++
+	if len(s.latencies) < s.maxSamples {
+		s.latencies = append(s.latencies, latency)
+		return
+	}
+
+	if i := rand.Uint64N(s.sampleCount); i < uint64(s.maxSamples) {
+		s.latencies[i] = latency
+	}
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	i := int(float64(len(sorted)-1) * p)
+	return sorted[i]
+}
+
+func (s *stats) progress(w io.Writer, elapsed time.Duration, lastSent, lastRecv uint64, interval time.Duration) (uint64, uint64) {
+	sent, recv := s.sent.Load(), s.received.Load()
+	fmt.Fprintf(w, "[%6.1fs] conns: %d, sent: %.0f/s, recv: %.0f/s, send errors: %d, reconnects: %d\n",
+		elapsed.Seconds(), s.connected.Load(), float64(sent-lastSent)/interval.Seconds(), float64(recv-lastRecv)/interval.Seconds(),
+		s.sendErrors.Load(), s.reconnects.Load())
+	return sent, recv
+}
+
+func (s *stats) report(w io.Writer, elapsed time.Duration) {
+	s.locker.Lock()
+	latencies := append([]time.Duration(nil), s.latencies...)
+	s.locker.Unlock()
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+
+	seconds := elapsed.Seconds()
+	fmt.Fprintf(w, "\nduration:    %s\n", elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "sent:        %d packets, %.0f packets/s, %.2f MB/s\n", s.sent.Load(), float64(s.sent.Load())/seconds, float64(s.bytesSent.Load())/seconds/1e6)
+	fmt.Fprintf(w, "received:    %d packets, %.0f packets/s, %.2f MB/s\n", s.received.Load(), float64(s.received.Load())/seconds, float64(s.bytesRecv.Load())/seconds/1e6)
+	fmt.Fprintf(w, "errors:      send %d, dial %d\n", s.sendErrors.Load(), s.dialErrors.Load())
+	fmt.Fprintf(w, "reconnects:  %d\n", s.reconnects.Load())
+	if len(latencies) == 0 {
+		fmt.Fprintf(w, "latency:     no echoed packets\n")
+		return
+	}
+
+	fmt.Fprintf(w, "latency:     p50 %s, p90 %s, p99 %s, p99.9 %s, max %s (%d samples)\n",
+		percentile(latencies, 0.5), percentile(latencies, 0.9), percentile(latencies, 0.99), percentile(latencies, 0.999),
+		latencies[len(latencies)-1], len(latencies))
+}
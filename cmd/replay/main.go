@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/kovey/network-go/v2/capture"
+	"github.com/kovey/network-go/v2/client"
+	"github.com/kovey/network-go/v2/connection"
+)
+
+type handler struct{}
+
+func (h *handler) Receive(*connection.Packet, *client.Client) error {
+	return nil
+}
+
+func (h *handler) Idle(*client.Client) error {
+	return nil
+}
+
+func (h *handler) Try(*client.Client) bool {
+	return false
+}
+
+func (h *handler) Shutdown() {
+}
+
+func main() {
+	file := flag.String("file", "", "capture file")
+	host := flag.String("host", "127.0.0.1", "server host")
+	port := flag.Int("port", 9910, "server port")
+	scale := flag.Float64("scale", 1, "the gaps between the packets are multiplied by scale, 0 sends without waiting")
+	fd := flag.Uint64("fd", 0, "only replay the packets of the connection fd, 0 is the first connection of the capture")
+	out := flag.Bool("out", false, "replay the written packets, for the capture recorded by a client")
+	flag.Parse()
+
+	if err := run(*file, *host, *port, *scale, *fd, *out); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(file, host string, port int, scale float64, fd uint64, out bool) error {
+	reader, err := capture.Open(file)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	replayer := capture.NewReplayer(reader).WithScale(scale).WithFD(fd)
+	if out {
+		replayer.WithDirection(capture.Direction_Out)
+	}
+
+	cli := client.NewClient().WithService(client.NewTcp()).WithHandler(&handler{})
+	if err := cli.Dial(host, port); err != nil {
+		return err
+	}
+	defer cli.Shutdown()
+	go cli.Listen()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	return replayer.ToClient(ctx, cli)
+}
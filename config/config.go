@@ -0,0 +1,187 @@
+package config
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var Err_Invalid = errors.New("invalid config")
+var Err_Unkown_Format = errors.New("unkown config format")
+
+// Error is the validation error of a field, errors.Is(err, Err_Invalid) is true
+type Error struct {
+	Field  string
+	Reason string
+}
+
+// Invalid create the validation error of field
+func Invalid(field, format string, args ...any) *Error {
+	return &Error{Field: field, Reason: fmt.Sprintf(format, args...)}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s: %s", Err_Invalid, e.Field, e.Reason)
+}
+
+func (e *Error) Unwrap() error {
+	return Err_Invalid
+}
+
+// Prefix put prefix before the field of the validation error, e.g. service.framing.header_len
+func Prefix(prefix string, err error) error {
+	var e *Error
+	if !errors.As(err, &e) {
+		return err
+	}
+
+	return &Error{Field: prefix + "." + e.Field, Reason: e.Reason}
+}
+
+// Duration is time.Duration written as "10s" in yaml, json and env
+type Duration time.Duration
+
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(v)
+	return nil
+}
+
+// Load decode the file of path into v, the format is chosen by the extension: .yaml, .yml or .json
+func Load(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return yaml.Unmarshal(data, v)
+	case ".json":
+		return json.Unmarshal(data, v)
+	}
+
+	return fmt.Errorf("%w: %s", Err_Unkown_Format, path)
+}
+
+// Env override the fields of v from the environment, the name of a field is prefix and the
+// upper case yaml names of its path joined by "_", e.g. NETWORK_SERVICE_FRAMING_HEADER_LEN
+func Env(prefix string, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config must be pointer to struct, %T given", v)
+	}
+
+	return env(strings.ToUpper(prefix), rv.Elem())
+}
+
+func env(prefix string, rv reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := envName(field)
+		if name == "-" {
+			continue
+		}
+		if prefix != "" {
+			name = prefix + "_" + name
+		}
+
+		fv := rv.Field(i)
+		if _, ok := fv.Addr().Interface().(encoding.TextUnmarshaler); !ok && fv.Kind() == reflect.Struct {
+			if err := env(name, fv); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := set(fv, value); err != nil {
+			return fmt.Errorf("env %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func envName(field reflect.StructField) string {
+	if tag := field.Tag.Get("env"); tag != "" {
+		return tag
+	}
+
+	if tag, _, _ := strings.Cut(field.Tag.Get("yaml"), ","); tag != "" {
+		if tag == "-" {
+			return tag
+		}
+		return strings.ToUpper(tag)
+	}
+
+	return strings.ToUpper(field.Name)
+}
+
+func set(fv reflect.Value, value string) error {
+	if u, ok := fv.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(value))
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(value)
+	case reflect.Bool:
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(v)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := strconv.ParseInt(value, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(v)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err := strconv.ParseUint(value, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(v)
+	case reflect.Float32, reflect.Float64:
+		v, err := strconv.ParseFloat(value, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(v)
+	default:
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type envFraming struct {
+	HeaderLen int    `yaml:"header_len"`
+	Endian    string `yaml:"endian"`
+}
+
+type envConfig struct {
+	Host    string     `yaml:"host"`
+	Port    int        `yaml:"port"`
+	Enable  bool       `yaml:"enable"`
+	Rate    float64    `yaml:"rate"`
+	Max     uint16     `yaml:"max"`
+	Timeout Duration   `yaml:"timeout"`
+	Framing envFraming `yaml:"framing"`
+	Secret  string     `yaml:"secret" env:"APP_SECRET"`
+	Skipped string     `yaml:"-"`
+}
+
+func TestEnv(t *testing.T) {
+	tests := []struct {
+		name   string
+		key    string
+		value  string
+		expect func(c envConfig) bool
+		err    bool
+	}{
+		{"string", "NET_HOST", "example.com", func(c envConfig) bool { return c.Host == "example.com" }, false},
+		{"int", "NET_PORT", "9911", func(c envConfig) bool { return c.Port == 9911 }, false},
+		{"bool", "NET_ENABLE", "true", func(c envConfig) bool { return c.Enable }, false},
+		{"float", "NET_RATE", "1.5", func(c envConfig) bool { return c.Rate == 1.5 }, false},
+		{"uint", "NET_MAX", "65535", func(c envConfig) bool { return c.Max == 65535 }, false},
+		{"duration", "NET_TIMEOUT", "3s", func(c envConfig) bool { return c.Timeout.Std() == 3*time.Second }, false},
+		{"nested", "NET_FRAMING_HEADER_LEN", "8", func(c envConfig) bool { return c.Framing.HeaderLen == 8 }, false},
+		{"env tag", "NET_APP_SECRET", "s", func(c envConfig) bool { return c.Secret == "s" }, false},
+		{"skipped", "NET_SKIPPED", "x", func(c envConfig) bool { return c.Skipped == "" }, false},
+		{"unset", "NET_OTHER", "x", func(c envConfig) bool { return c.Host == "localhost" && c.Framing.Endian == "big" }, false},
+		{"bad int", "NET_PORT", "abc", nil, true},
+		{"uint overflow", "NET_MAX", "65536", nil, true},
+		{"bad duration", "NET_TIMEOUT", "3", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+			c := envConfig{Host: "localhost", Framing: envFraming{Endian: "big"}}
+			err := Env("net", &c)
+			if tt.err {
+				if err == nil {
+					t.Fatal(c)
+				}
+				return
+			}
+			if err != nil || !tt.expect(c) {
+				t.Fatal(err, c)
+			}
+		})
+	}
+
+	if err := Env("net", envConfig{}); err == nil {
+		t.Fatal("not pointer is accepted")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name string
+		file string
+		data string
+		err  error
+	}{
+		{"yaml", "c.yaml", "host: example.com\nframing: {header_len: 8}\n", nil},
+		{"yml", "c.yml", "host: example.com\nframing: {header_len: 8}\n", nil},
+		{"json", "c.json", `{"host":"example.com","framing":{"header_len":8}}`, nil},
+		{"unkown format", "c.toml", "host = 1", Err_Unkown_Format},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			if err := os.WriteFile(path, []byte(tt.data), 0600); err != nil {
+				t.Fatal(err)
+			}
+			var c struct {
+				Host    string `yaml:"host" json:"host"`
+				Framing struct {
+					HeaderLen int `yaml:"header_len" json:"header_len"`
+				} `yaml:"framing" json:"framing"`
+			}
+			err := Load(path, &c)
+			if !errors.Is(err, tt.err) {
+				t.Fatal(err)
+			}
+			if tt.err == nil && (c.Host != "example.com" || c.Framing.HeaderLen != 8) {
+				t.Fatal(c)
+			}
+		})
+	}
+}
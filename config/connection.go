@@ -0,0 +1,300 @@
+package config
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kovey/network-go/v2/connection"
+)
+
+var lenTypes = map[string]connection.LenType{
+	"int8": connection.Len_Type_Int8, "int16": connection.Len_Type_Int16, "int32": connection.Len_Type_Int32, "int64": connection.Len_Type_Int64,
+	"uint8": connection.Len_Type_UInt8, "uint16": connection.Len_Type_UInt16, "uint32": connection.Len_Type_UInt32, "uint64": connection.Len_Type_UInt64,
+}
+
+var ciphers = map[string]connection.CipherSuite{
+	"aes-128-gcm": connection.Cipher_AES_128_GCM, "aes-256-gcm": connection.Cipher_AES_256_GCM, "chacha20-poly1305": connection.Cipher_ChaCha20_Poly1305,
+}
+
+// Framing is the layout of the packet header
+type Framing struct {
+	HeaderLen     int      `yaml:"header_len" json:"header_len"`
+	BodyLenOffset int      `yaml:"body_len_offset" json:"body_len_offset"`
+	BodyLenType   string   `yaml:"body_len_type" json:"body_len_type"` // int8, int16, int32, int64, uint8, uint16, uint32, uint64
+	Endian        string   `yaml:"endian" json:"endian"`               // big or little
+	Fields        []Field  `yaml:"fields" json:"fields"`               // the named fields of the header besides the body length
+	Checksum      Checksum `yaml:"checksum" json:"checksum"`
+	Kind          Kind     `yaml:"kind" json:"kind"`
+}
+
+// Kind is the byte of the header tells the stream, mux, push and resume frames from the application packets
+type Kind struct {
+	Enable bool `yaml:"enable" json:"enable"`
+	Offset int  `yaml:"offset" json:"offset"`
+}
+
+// KindOffset the offset of the kind, -1 when disabled
+func (k Kind) KindOffset() int {
+	if !k.Enable {
+		return -1
+	}
+
+	return k.Offset
+}
+
+var checksums = map[string]connection.ChecksumType{"crc32": connection.Checksum_CRC32, "crc32c": connection.Checksum_CRC32C, "xxhash": connection.Checksum_XXHash}
+
+var checksumActions = map[string]connection.ChecksumAction{"close": connection.Checksum_Close, "": connection.Checksum_Close, "drop": connection.Checksum_Drop}
+
+// Checksum is the checksum field of the body in the header, empty type is disabled
+type Checksum struct {
+	Type   string `yaml:"type" json:"type"` // crc32, crc32c or xxhash
+	Offset int    `yaml:"offset" json:"offset"`
+	Action string `yaml:"action" json:"action"` // close or drop the packet whose checksum mismatches, the default is close
+}
+
+func (c Checksum) ChecksumType() (connection.ChecksumType, bool) {
+	if c.Type == "" {
+		return 0, true
+	}
+
+	t, ok := checksums[strings.ToLower(c.Type)]
+	return t, ok
+}
+
+func (c Checksum) ChecksumAction() (connection.ChecksumAction, bool) {
+	a, ok := checksumActions[strings.ToLower(c.Action)]
+	return a, ok
+}
+
+// Field is the named integer field of the header
+type Field struct {
+	Name   string `yaml:"name" json:"name"`
+	Offset int    `yaml:"offset" json:"offset"`
+	Width  int    `yaml:"width" json:"width"` // 1, 2, 4 or 8
+	Signed bool   `yaml:"signed" json:"signed"`
+	Endian string `yaml:"endian" json:"endian"` // the endian of the framing when empty
+}
+
+func DefaultFraming() Framing {
+	return Framing{HeaderLen: 4, BodyLenOffset: 0, BodyLenType: "int32", Endian: "big"}
+}
+
+func (f Framing) LenType() (connection.LenType, bool) {
+	t, ok := lenTypes[strings.ToLower(f.BodyLenType)]
+	return t, ok
+}
+
+func (f Framing) ByteOrder() (binary.ByteOrder, bool) {
+	switch strings.ToLower(f.Endian) {
+	case "big", "":
+		return binary.BigEndian, true
+	case "little":
+		return binary.LittleEndian, true
+	}
+
+	return nil, false
+}
+
+// Schema the schema of the fields, nil when there is no field
+func (f Framing) Schema() *connection.Schema {
+	if len(f.Fields) == 0 {
+		return nil
+	}
+
+	fields := make([]connection.HeaderField, len(f.Fields))
+	for i, field := range f.Fields {
+		fields[i] = connection.HeaderField{Name: field.Name, Offset: field.Offset, Width: field.Width, Signed: field.Signed}
+		if field.Endian != "" {
+			fields[i].Endian, _ = Framing{Endian: field.Endian}.ByteOrder()
+		}
+	}
+
+	return connection.NewSchema(fields...)
+}
+
+func (f Framing) Validate() error {
+	t, ok := f.LenType()
+	if !ok {
+		return Invalid("body_len_type", "%q is unkown, must be one of int8, int16, int32, int64, uint8, uint16, uint32, uint64", f.BodyLenType)
+	}
+	if _, ok := f.ByteOrder(); !ok {
+		return Invalid("endian", "%q is unkown, must be big or little", f.Endian)
+	}
+	if f.HeaderLen <= 0 {
+		return Invalid("header_len", "must be positive, %d given", f.HeaderLen)
+	}
+	if f.BodyLenOffset < 0 {
+		return Invalid("body_len_offset", "must not be negative, %d given", f.BodyLenOffset)
+	}
+	if width := t.Width(); f.BodyLenOffset+width > f.HeaderLen {
+		return Invalid("body_len_offset", "body_len_offset(%d) + width of %s(%d) > header_len(%d)", f.BodyLenOffset, f.BodyLenType, width, f.HeaderLen)
+	}
+	for i, field := range f.Fields {
+		if _, ok := (Framing{Endian: field.Endian}).ByteOrder(); !ok {
+			return Invalid(fmt.Sprintf("fields[%d].endian", i), "%q is unkown, must be big or little", field.Endian)
+		}
+	}
+	if f.Kind.Enable && f.Kind.Offset < 0 {
+		return Invalid("kind.offset", "must not be negative, %d given", f.Kind.Offset)
+	}
+	if _, ok := f.Checksum.ChecksumType(); !ok {
+		return Invalid("checksum.type", "%q is unkown, must be one of crc32, crc32c, xxhash", f.Checksum.Type)
+	}
+	if _, ok := f.Checksum.ChecksumAction(); !ok {
+		return Invalid("checksum.action", "%q is unkown, must be close or drop", f.Checksum.Action)
+	}
+	if err := f.Header().Validate(); err != nil {
+		return Invalid("layout", "%s", err)
+	}
+
+	return nil
+}
+
+// Header build the header of the layout, Validate should be called before
+func (f Framing) Header() *connection.Header {
+	t, _ := f.LenType()
+	e, _ := f.ByteOrder()
+	c, _ := f.Checksum.ChecksumType()
+	return connection.NewHeader().WithHeaderLen(f.HeaderLen).WithBodyLenOffset(f.BodyLenOffset).WithBodyLenType(t).WithEndian(e).WithSchema(f.Schema()).WithChecksum(c, f.Checksum.Offset).WithKind(f.Kind.KindOffset())
+}
+
+// Limits is the size limits of the connection
+type Limits struct {
+	MaxLen      int      `yaml:"max_len" json:"max_len"`             // max length of a packet
+	ReadBuffLen int      `yaml:"read_buff_len" json:"read_buff_len"` // initial length of the read buffer
+	ShrinkAfter Duration `yaml:"shrink_after" json:"shrink_after"`   // the grown read buffer shrinks back after idle
+	ZeroCopy    int      `yaml:"zero_copy" json:"zero_copy"`         // packets not longer than it reference the read buffer, the longer ones are copied, 0 is disabled
+}
+
+func DefaultLimits() Limits {
+	return Limits{MaxLen: 8192, ReadBuffLen: 1024, ShrinkAfter: Duration(30 * time.Second)}
+}
+
+func (l Limits) Validate(headerLen int) error {
+	if l.MaxLen <= headerLen {
+		return Invalid("max_len", "must be larger than header_len(%d), %d given", headerLen, l.MaxLen)
+	}
+	if l.ReadBuffLen < 0 {
+		return Invalid("read_buff_len", "must not be negative, %d given", l.ReadBuffLen)
+	}
+	if l.ShrinkAfter < 0 {
+		return Invalid("shrink_after", "must not be negative, %s given", l.ShrinkAfter.Std())
+	}
+	if l.ZeroCopy < 0 {
+		return Invalid("zero_copy", "must not be negative, %d given", l.ZeroCopy)
+	}
+
+	return nil
+}
+
+// Timeouts of the connection, 0 is disabled
+type Timeouts struct {
+	Read      Duration `yaml:"read" json:"read"`
+	Write     Duration `yaml:"write" json:"write"`
+	Handshake Duration `yaml:"handshake" json:"handshake"`
+}
+
+func (t Timeouts) Validate() error {
+	names := []string{"read", "write", "handshake"}
+	for i, d := range []Duration{t.Read, t.Write, t.Handshake} {
+		if d < 0 {
+			return Invalid(names[i], "must not be negative, %s given", d.Std())
+		}
+	}
+
+	return nil
+}
+
+// Secure is the encryption after the ECDH handshake, empty cipher is disabled
+type Secure struct {
+	Cipher      string `yaml:"cipher" json:"cipher"` // aes-128-gcm, aes-256-gcm or chacha20-poly1305
+	RotateEvery uint64 `yaml:"rotate_every" json:"rotate_every"`
+	PSK         string `yaml:"psk" json:"psk"` // the pre-shared key authenticates the handshake, both sides must have the same
+}
+
+func (s Secure) Enabled() bool {
+	return s.Cipher != ""
+}
+
+func (s Secure) Suite() (connection.CipherSuite, bool) {
+	c, ok := ciphers[strings.ToLower(s.Cipher)]
+	return c, ok
+}
+
+func (s Secure) Validate() error {
+	if !s.Enabled() {
+		return nil
+	}
+	if _, ok := s.Suite(); !ok {
+		return Invalid("cipher", "%q is unkown, must be one of aes-128-gcm, aes-256-gcm, chacha20-poly1305", s.Cipher)
+	}
+
+	return nil
+}
+
+// Sampling limits the log records of every connection to burst in every interval, 0 burst is disabled
+type Sampling struct {
+	Burst    int      `yaml:"burst" json:"burst"`
+	Interval Duration `yaml:"interval" json:"interval"`
+}
+
+func (s Sampling) Validate() error {
+	if s.Burst < 0 {
+		return Invalid("burst", "must not be negative, %d given", s.Burst)
+	}
+	if s.Burst > 0 && s.Interval <= 0 {
+		return Invalid("interval", "must be positive when burst is set, %s given", s.Interval.Std())
+	}
+
+	return nil
+}
+
+var features = map[string]connection.Feature{"encryption": connection.Feature_Encryption}
+
+// Negotiation is the protocol versions and features negotiated in the handshake, no versions is disabled
+type Negotiation struct {
+	Versions []uint16 `yaml:"versions" json:"versions"`
+	Features []string `yaml:"features" json:"features"` // encryption or the bit of an application feature, e.g. 0x100
+	Required []string `yaml:"required" json:"required"` // the features the peer must support
+}
+
+func (n Negotiation) Flags() (supported, required connection.Feature, err error) {
+	if supported, err = flags("features", n.Features); err != nil {
+		return
+	}
+
+	required, err = flags("required", n.Required)
+	return
+}
+
+func flags(field string, names []string) (connection.Feature, error) {
+	var f connection.Feature
+	for i, name := range names {
+		flag, ok := features[strings.ToLower(name)]
+		if !ok {
+			bit, err := strconv.ParseUint(name, 0, 32)
+			if err != nil || bit == 0 || bit&(bit-1) != 0 {
+				return 0, Invalid(fmt.Sprintf("%s[%d]", field, i), "%q is unkown, must be encryption or a single bit, e.g. 0x100", name)
+			}
+			flag = connection.Feature(bit)
+		}
+		f |= flag
+	}
+
+	return f, nil
+}
+
+func (n Negotiation) Validate() error {
+	if _, _, err := n.Flags(); err != nil {
+		return err
+	}
+	if len(n.Versions) == 0 && len(n.Features)+len(n.Required) > 0 {
+		return Invalid("versions", "is required by the features")
+	}
+
+	return nil
+}
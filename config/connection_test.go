@@ -0,0 +1,40 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/kovey/network-go/v2/connection"
+)
+
+func TestNegotiationFlags(t *testing.T) {
+	tests := []struct {
+		name      string
+		features  []string
+		required  []string
+		supported connection.Feature
+		err       bool
+	}{
+		{"encryption", []string{"Encryption"}, nil, connection.Feature_Encryption, false},
+		{"application bit", []string{"0x100", "512"}, []string{"0x100"}, 1<<8 | 1<<9, false},
+		{"removed name", []string{"compression"}, nil, 0, true},
+		{"not a single bit", []string{"0x3"}, nil, 0, true},
+		{"zero", nil, []string{"0"}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := Negotiation{Versions: []uint16{1}, Features: tt.features, Required: tt.required}
+			supported, _, err := n.Flags()
+			if tt.err {
+				if !errors.Is(err, Err_Invalid) || n.Validate() == nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err != nil || supported != tt.supported {
+				t.Fatal(supported, err)
+			}
+		})
+	}
+}
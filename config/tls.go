@@ -0,0 +1,103 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// TLS of the server or client, the server enables it when cert_file is set
+type TLS struct {
+	Enable             bool   `yaml:"enable" json:"enable"` // the client enables tls with the system roots
+	CertFile           string `yaml:"cert_file" json:"cert_file"`
+	KeyFile            string `yaml:"key_file" json:"key_file"`
+	CAFile             string `yaml:"ca_file" json:"ca_file"`         // the roots to verify the peer, system roots when empty
+	ServerName         string `yaml:"server_name" json:"server_name"` // the host dialed when empty
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify" json:"insecure_skip_verify"`
+	ClientAuth         bool   `yaml:"client_auth" json:"client_auth"` // the server requires the client certificate verified by ca_file
+}
+
+func (t TLS) Enabled() bool {
+	return t.Enable || t.CertFile != ""
+}
+
+func (t TLS) Validate(server bool) error {
+	if !t.Enabled() {
+		return nil
+	}
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return Invalid("key_file", "cert_file and key_file must be set together")
+	}
+	if server && t.CertFile == "" {
+		return Invalid("cert_file", "is required by the server")
+	}
+	if server && t.ClientAuth && t.CAFile == "" {
+		return Invalid("ca_file", "is required by client_auth")
+	}
+
+	return nil
+}
+
+// Server the tls config of the server, nil when tls is disabled
+func (t TLS) Server() (*tls.Config, error) {
+	if !t.Enabled() {
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if t.ClientAuth {
+		pool, err := t.pool()
+		if err != nil {
+			return nil, err
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return cfg, nil
+}
+
+// Client the tls config of the client, nil when tls is disabled
+func (t TLS) Client() (*tls.Config, error) {
+	if !t.Enabled() {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{ServerName: t.ServerName, InsecureSkipVerify: t.InsecureSkipVerify, MinVersion: tls.VersionTLS12}
+	if t.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if t.CAFile != "" {
+		pool, err := t.pool()
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+
+	return cfg, nil
+}
+
+func (t TLS) pool() (*x509.CertPool, error) {
+	data, err := os.ReadFile(t.CAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificate in %s", t.CAFile)
+	}
+
+	return pool, nil
+}
@@ -0,0 +1,76 @@
+package connection
+
+import "sync"
+
+type attributes struct {
+	locker  sync.RWMutex
+	values  map[string]any
+	onClose []func(*Connection)
+}
+
+func (c *Connection) Set(key string, value any) {
+	c.attrs.locker.Lock()
+	defer c.attrs.locker.Unlock()
+	if c.attrs.values == nil {
+		c.attrs.values = make(map[string]any)
+	}
+	c.attrs.values[key] = value
+}
+
+func (c *Connection) Get(key string) (any, bool) {
+	c.attrs.locker.RLock()
+	defer c.attrs.locker.RUnlock()
+	value, ok := c.attrs.values[key]
+	return value, ok
+}
+
+func (c *Connection) Delete(key string) {
+	c.attrs.locker.Lock()
+	defer c.attrs.locker.Unlock()
+	delete(c.attrs.values, key)
+}
+
+// Range call fn for every attribute until fn return false
+func (c *Connection) Range(fn func(key string, value any) bool) {
+	c.attrs.locker.RLock()
+	defer c.attrs.locker.RUnlock()
+	for key, value := range c.attrs.values {
+		if !fn(key, value) {
+			return
+		}
+	}
+}
+
+// OnClose register fn called when the connection is closed, before the attributes are cleared
+func (c *Connection) OnClose(fn func(*Connection)) {
+	c.attrs.locker.Lock()
+	defer c.attrs.locker.Unlock()
+	c.attrs.onClose = append(c.attrs.onClose, fn)
+}
+
+func (c *Connection) cleanup() {
+	c.attrs.locker.Lock()
+	callbacks := c.attrs.onClose
+	c.attrs.onClose = nil
+	c.attrs.locker.Unlock()
+
+	for _, fn := range callbacks {
+		fn(c)
+	}
+
+	c.attrs.locker.Lock()
+	c.attrs.values = nil
+	c.attrs.locker.Unlock()
+}
+
+// Attr typed get of the attribute, ok is false when the key is not exists or the type is not T
+func Attr[T any](c *Connection, key string) (T, bool) {
+	value, ok := c.Get(key)
+	if !ok {
+		var zero T
+		return zero, false
+	}
+
+	t, ok := value.(T)
+	return t, ok
+}
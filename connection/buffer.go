@@ -0,0 +1,81 @@
+package connection
+
+import (
+	"math/bits"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	min_buff_shift = 6  // 64B
+	max_buff_shift = 22 // 4MB
+)
+
+// buffPools is size classed by power of two from 64B to 4MB
+var buffPools [max_buff_shift - min_buff_shift + 1]sync.Pool
+
+func buffClass(size int) int {
+	if size <= 1<<min_buff_shift {
+		return 0
+	}
+
+	return bits.Len(uint(size-1)) - min_buff_shift
+}
+
+// Alloc get a buffer with length size from the pool, the capacity is rounded up to the size class
+func Alloc(size int) []byte {
+	class := buffClass(size)
+	if class >= len(buffPools) {
+		return make([]byte, size)
+	}
+
+	if buff, ok := buffPools[class].Get().(*[]byte); ok {
+		return (*buff)[:size]
+	}
+
+	return make([]byte, size, 1<<(class+min_buff_shift))
+}
+
+// Free put the buffer allocated by Alloc back to the pool, the buffer must not be used after Free
+func Free(buff []byte) {
+	c := cap(buff)
+	if c < 1<<min_buff_shift || c&(c-1) != 0 {
+		return
+	}
+
+	class := buffClass(c)
+	if class >= len(buffPools) {
+		return
+	}
+
+	buff = buff[:0]
+	buffPools[class].Put(&buff)
+}
+
+// chunk is a pooled buffer shared by the connection and the zero copy packets read into it
+type chunk struct {
+	buff []byte
+	refs atomic.Int32
+}
+
+func newChunk(size int) *chunk {
+	c := &chunk{buff: Alloc(size)}
+	c.refs.Store(1)
+	return c
+}
+
+func (c *chunk) retain() *chunk {
+	c.refs.Add(1)
+	return c
+}
+
+func (c *chunk) shared() bool {
+	return c.refs.Load() > 1
+}
+
+func (c *chunk) release() {
+	if c.refs.Add(-1) == 0 {
+		Free(c.buff)
+		c.buff = nil
+	}
+}
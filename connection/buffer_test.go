@@ -0,0 +1,71 @@
+package connection
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestReadBuffGrow(t *testing.T) {
+	tests := []struct {
+		name    string
+		bodyLen int
+		buffLen int
+		err     error
+	}{
+		{"initial", 8, 16, nil},
+		{"grown", 600, 604, nil},
+		{"max length", 1020, 1024, nil},
+		{"out of range", 1021, 0, Err_Packet_Out_Range},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := net.Pipe()
+			defer a.Close()
+			defer b.Close()
+			c := NewConnection(1, a).WithReadBuffLen(16).WithMaxLen(1024)
+			body := bytes.Repeat([]byte{1}, tt.bodyLen)
+			go b.Write(NewPacket(body, c.Header()).Bytes())
+
+			p, err := c.Read()
+			if !errors.Is(err, tt.err) {
+				t.Fatal(err)
+			}
+			if tt.err != nil {
+				return
+			}
+			if !bytes.Equal(p.Body, body) || len(c.chunk.buff) < tt.buffLen || len(c.chunk.buff) > 1024 {
+				t.Fatal(len(p.Body), len(c.chunk.buff))
+			}
+		})
+	}
+}
+
+func TestReadBuffShrink(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+	c := NewConnection(1, a).WithReadBuffLen(16).WithMaxLen(1024).WithShrinkAfter(20 * time.Millisecond)
+	go func() {
+		b.Write(NewPacket(make([]byte, 600), c.Header()).Bytes())
+		time.Sleep(100 * time.Millisecond)
+		b.Write(NewPacket([]byte("small"), c.Header()).Bytes())
+	}()
+
+	if _, err := c.Read(); err != nil || len(c.chunk.buff) < 604 {
+		t.Fatal(err)
+	}
+	p, err := c.Read()
+	if err != nil || string(p.Body) != "small" {
+		t.Fatal(p, err)
+	}
+	if len(c.chunk.buff) != 16 {
+		t.Fatal(len(c.chunk.buff))
+	}
+	if c.Stats().ReadTimeouts != 0 {
+		t.Fatal(c.Stats().ReadTimeouts)
+	}
+}
@@ -0,0 +1,169 @@
+package connection
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/kovey/network-go/v2/logger"
+)
+
+var Err_Checksum = NewError(Err_Protocol, errors.New("checksum mismatch"))
+
+// ChecksumType is the algorithm of the checksum field over the body
+type ChecksumType byte
+
+const (
+	Checksum_CRC32  ChecksumType = 1 // IEEE, 4 bytes
+	Checksum_CRC32C ChecksumType = 2 // Castagnoli, 4 bytes
+	Checksum_XXHash ChecksumType = 3 // xxhash64, 8 bytes
+)
+
+// ChecksumAction is what the read does with the packet whose checksum mismatches
+type ChecksumAction byte
+
+const (
+	Checksum_Close   ChecksumAction = 0 // the read fails with Err_Checksum
+	Checksum_Drop    ChecksumAction = 1 // the packet is dropped and the read goes on
+	Checksum_Handler ChecksumAction = 2 // the handler is called
+)
+
+// ChecksumHandler is called with the mismatched packet, the packet is dropped when it returns nil,
+// otherwise the read fails with the error, the packet must not be used after it returns
+type ChecksumHandler func(c *Connection, p *Packet) error
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Width the bytes of the checksum field, 0 when the type is unkown
+func (t ChecksumType) Width() int {
+	switch t {
+	case Checksum_CRC32, Checksum_CRC32C:
+		return 4
+	case Checksum_XXHash:
+		return 8
+	default:
+		return 0
+	}
+}
+
+func (t ChecksumType) sum(body []byte) uint64 {
+	switch t {
+	case Checksum_CRC32:
+		return uint64(crc32.ChecksumIEEE(body))
+	case Checksum_CRC32C:
+		return uint64(crc32.Checksum(body, castagnoli))
+	case Checksum_XXHash:
+		return xxhash.Sum64(body)
+	default:
+		return 0
+	}
+}
+
+type checksum struct {
+	t      ChecksumType
+	offset int
+}
+
+// WithChecksum the checksum of the body is written at offset of the header, 0 type disables it
+func (h *Header) WithChecksum(t ChecksumType, offset int) *Header {
+	if t == 0 {
+		h.checksum = nil
+		return h
+	}
+
+	h.checksum = &checksum{t: t, offset: offset}
+	return h
+}
+
+func (h *Header) validateChecksum() error {
+	cs := h.checksum
+	width := cs.t.Width()
+	if width == 0 {
+		return fmt.Errorf("%w: unkown checksum type %d", Err_Invalid_Header, cs.t)
+	}
+	if cs.offset < 0 || cs.offset+width > h.headerLen {
+		return fmt.Errorf("%w: checksum offset(%d) + width(%d) > header length(%d)", Err_Invalid_Header, cs.offset, width, h.headerLen)
+	}
+	if overlap(cs.offset, width, h.bodyLenOffset, h.bodyLengthLen) {
+		return fmt.Errorf("%w: checksum overlaps the body length", Err_Invalid_Header)
+	}
+	if h.schema != nil {
+		for _, f := range h.schema.fields {
+			if overlap(cs.offset, width, f.Offset, f.Width) {
+				return fmt.Errorf("%w: checksum overlaps field %s", Err_Invalid_Header, f.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (cs *checksum) put(h *Header, headers, body []byte) {
+	field := headers[cs.offset : cs.offset+cs.t.Width()]
+	if len(field) == 4 {
+		h.endian.PutUint32(field, uint32(cs.t.sum(body)))
+		return
+	}
+
+	h.endian.PutUint64(field, cs.t.sum(body))
+}
+
+func (cs *checksum) verify(h *Header, headers, body []byte) bool {
+	field := headers[cs.offset : cs.offset+cs.t.Width()]
+	if len(field) == 4 {
+		return h.endian.Uint32(field) == uint32(cs.t.sum(body))
+	}
+
+	return h.endian.Uint64(field) == cs.t.sum(body)
+}
+
+// WithChecksumAction what the read does with the packet whose checksum mismatches, handler is used by Checksum_Handler
+func (c *Connection) WithChecksumAction(action ChecksumAction, handler ChecksumHandler) *Connection {
+	c.checksumAction = action
+	c.checksumHandler = handler
+	return c
+}
+
+// WithChecksum the checksum of the body is written at offset of the header and verified by the read
+func (c *Connection) WithChecksum(t ChecksumType, offset int) *Connection {
+	c.header.WithChecksum(t, offset)
+	return c
+}
+
+// withChecksum the copy of data with the checksums of the packets, data is not changed
+func (c *Connection) withChecksum(data []byte) ([]byte, error) {
+	out := make([]byte, len(data))
+	copy(out, data)
+	err := c.frames(out, func(header, body []byte) error {
+		c.header.checksum.put(c.header, header, body)
+		return nil
+	})
+
+	return out, err
+}
+
+// checkSum verify the checksum of the packet, it returns false when the packet is dropped
+func (c *Connection) checkSum(p *Packet) (bool, error) {
+	if c.header.checksum.verify(c.header, p.Header, p.Body) {
+		return true, nil
+	}
+
+	c.counters.checksumFailures.Add(1)
+	c.Logger().Warn("checksum mismatch", logger.F("body_len", len(p.Body)))
+	switch c.checksumAction {
+	case Checksum_Drop:
+		p.Release()
+		return false, nil
+	case Checksum_Handler:
+		var err error
+		if c.checksumHandler != nil {
+			err = c.checksumHandler(c, p)
+		}
+		p.Release()
+		return false, err
+	default:
+		p.Release()
+		return false, Err_Checksum
+	}
+}
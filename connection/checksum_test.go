@@ -0,0 +1,90 @@
+package connection
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestChecksumDrop(t *testing.T) {
+	tests := []struct {
+		name     string
+		checksum ChecksumType
+	}{
+		{"crc32", Checksum_CRC32},
+		{"crc32c", Checksum_CRC32C},
+		{"xxhash", Checksum_XXHash},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := net.Pipe()
+			defer b.Close()
+			writer := NewConnection(1, a).WithHeaderLen(12).WithChecksum(tt.checksum, 4)
+			reader := NewConnection(2, b).WithHeaderLen(12).WithChecksum(tt.checksum, 4).WithChecksumAction(Checksum_Drop, nil)
+			if err := writer.Validate(); err != nil {
+				t.Fatal(err)
+			}
+
+			good := NewPacket([]byte("hello"), writer.Header()).Bytes()
+			go func() {
+				writer.Write(good)
+				// the raw copy has no checksum and the second copy is corrupted
+				bad := append(append([]byte(nil), good...), good...)
+				bad[len(bad)-1] ^= 1
+				a.Write(bad)
+				writer.Write(NewPacket([]byte("world"), writer.Header()).Bytes())
+			}()
+
+			for _, body := range []string{"hello", "world"} {
+				if p, err := reader.Read(); err != nil || string(p.Body) != body {
+					t.Fatal(p, err)
+				}
+			}
+			if reader.Stats().ChecksumFailures != 2 {
+				t.Fatal(reader.Stats())
+			}
+			if good[4] != 0 {
+				t.Fatal("the buffer of the caller is changed")
+			}
+		})
+	}
+}
+
+func TestChecksumAction(t *testing.T) {
+	mine := errors.New("mine")
+	bad := []byte{0, 0, 0, 1, 9, 9, 9, 9, 'x'}
+	tests := []struct {
+		name   string
+		action ChecksumAction
+		fail   int
+		err    error
+		called int
+	}{
+		{"close", Checksum_Close, 0, Err_Checksum, 0},
+		{"handler", Checksum_Handler, 2, mine, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := net.Pipe()
+			defer a.Close()
+			called := 0
+			c := NewConnection(2, b).WithChecksum(Checksum_CRC32, 4).WithHeaderLen(8).WithChecksumAction(tt.action, func(*Connection, *Packet) error {
+				called++
+				if called == tt.fail {
+					return mine
+				}
+				return nil
+			})
+			go a.Write(append(append([]byte(nil), bad...), bad...))
+			_, err := c.Read()
+			if !errors.Is(err, tt.err) || called != tt.called {
+				t.Fatal(err, called)
+			}
+			if tt.err == Err_Checksum && KindOf(err) != Err_Protocol {
+				t.Fatal(KindOf(err))
+			}
+		})
+	}
+}
@@ -1,16 +1,25 @@
 package connection
 
 import (
-	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
+	"fmt"
+	"io"
 	"net"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/kovey/network-go/v2/logger"
 )
 
-var Err_Closed = errors.New("connection is closed")
-var Err_Unkown_Body_Len_Type = errors.New("unkown body length type")
-var Err_Packet_Out_Range = errors.New("packet out of range")
+var Err_Closed = NewError(Err_IO, errors.New("connection is closed"))
+var Err_Unkown_Body_Len_Type = NewError(Err_Protocol, errors.New("unkown body length type"))
+var Err_Packet_Out_Range = NewError(Err_Oversize, errors.New("packet out of range"))
+var Err_Read_Timeout = NewError(Err_Idle_Timeout, errors.New("read timeout"))
+var Err_Write_Timeout = NewError(Err_IO, errors.New("write timeout"))
+var Err_Handshake_Timeout = NewError(Err_Idle_Timeout, errors.New("handshake timeout"))
 
 type LenType byte
 
@@ -26,17 +35,47 @@ const (
 )
 
 type Connection struct {
-	conn           net.Conn
-	header         *Header
-	maxLen         int
-	packBuff       []byte
-	readLen        int
-	fd             uint64
-	isClosed       bool
-	connectTime    int64         // nano seconds
-	lastActiveTime int64         // nano seconds
-	maxIdleTime    time.Duration // max idle time
-	packets        chan *Packet
+	conn             net.Conn
+	header           *Header
+	maxLen           int
+	chunk            *chunk // read buffer
+	start            int    // offset of the unread bytes in chunk
+	readLen          int    // length of the unread bytes
+	zeroCopy         int
+	buffLen          int           // initial length of the read buffer
+	shrinkAfter      time.Duration // shrink the grown read buffer after idle
+	fd               uint64
+	isClosed         atomic.Bool
+	connectTime      int64         // nano seconds
+	lastActiveTime   atomic.Int64  // nano seconds
+	maxIdleTime      time.Duration // max idle time
+	packets          chan *Packet
+	packetsOnce      sync.Once
+	secure           *Secure
+	checksumAction   ChecksumAction
+	checksumHandler  ChecksumHandler
+	negotiator       *Negotiator
+	negotiated       atomic.Pointer[Negotiation]
+	transcript       []byte // the negotiation frames bound into the secure keys
+	identity         any
+	attrs            attributes
+	parent           context.Context
+	ctx              context.Context
+	cancel           context.CancelFunc
+	readDeadline     time.Time   // deadline of ReadContext
+	hasDeadline      bool        // a read deadline is applied to conn
+	interrupted      atomic.Bool // the read is cancelled by the context of ReadContext
+	readTimeout      time.Duration
+	writeTimeout     time.Duration
+	handshakeTimeout time.Duration
+	writeLocker      sync.Mutex
+	hasWriteDeadline bool
+	counters         counters
+	err              atomic.Value // the error ended the read
+	reason           atomic.Value // the reason the connection closed
+	baseLogger       logger.ILogger
+	tap              ITap
+	logger           logger.ILogger
 }
 
 func NewConnection(fd uint64, conn net.Conn) *Connection {
@@ -45,19 +84,86 @@ func NewConnection(fd uint64, conn net.Conn) *Connection {
 
 func NewConnectionBy(header *Header, fd uint64, conn net.Conn) *Connection {
 	now := time.Now()
-	return &Connection{conn: conn, maxLen: 8192, header: header, fd: fd, connectTime: now.UnixNano(), lastActiveTime: now.UnixNano(), packets: make(chan *Packet, 1024)}
+	c := &Connection{conn: conn, maxLen: 8192, buffLen: 1024, shrinkAfter: 30 * time.Second, header: header, fd: fd, connectTime: now.UnixNano()}
+	c.lastActiveTime.Store(now.UnixNano())
+	c.logger = c.newLogger()
+	return c.WithContext(context.Background())
 }
 
 func (c *Connection) Header() *Header {
 	return c.header
 }
 
+// Validate reject the header layout the length field does not fit in and the max length can not hold the header
+func (c *Connection) Validate() error {
+	if err := c.header.Validate(); err != nil {
+		return err
+	}
+	if c.maxLen <= c.header.headerLen {
+		return fmt.Errorf("%w: max length(%d) <= header length(%d)", Err_Invalid_Header, c.maxLen, c.header.headerLen)
+	}
+
+	return nil
+}
+
 func (c *Connection) WithConn(conn net.Conn) *Connection {
 	c.readLen = 0
+	c.start = 0
+	c.isClosed.Store(false)
 	c.conn = conn
+	c.packets = nil
+	c.packetsOnce = sync.Once{}
+	c.err.Store(errorBox{})
+	c.reason.Store(errorBox{})
+	c.logger = c.newLogger()
+	c.hasDeadline = false
+	c.hasWriteDeadline = false
+	if c.ctx.Err() != nil {
+		c.WithContext(c.parent)
+	}
 	return c
 }
 
+// WithLogger the logger of the connection, the fd and remote address are added to every record,
+// every connection samples its own records when the logger is a logger.Sampler
+func (c *Connection) WithLogger(l logger.ILogger) *Connection {
+	c.baseLogger = l
+	c.logger = c.newLogger()
+	return c
+}
+
+func (c *Connection) newLogger() logger.ILogger {
+	var remote net.Addr
+	if c.conn != nil {
+		remote = c.conn.RemoteAddr()
+	}
+
+	return logger.Or(c.baseLogger).With(logger.FD(c.fd), logger.Remote(remote))
+}
+
+// Logger the logger with the fd and remote address of the connection
+func (c *Connection) Logger() logger.ILogger {
+	return c.logger
+}
+
+// WithIdentity bind the authenticated identity to the connection
+func (c *Connection) WithIdentity(identity any) *Connection {
+	c.identity = identity
+	return c
+}
+
+func (c *Connection) Identity() any {
+	return c.identity
+}
+
+func (c *Connection) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *Connection) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
 func (c *Connection) FD() uint64 {
 	return c.fd
 }
@@ -67,7 +173,16 @@ func (c *Connection) Expired(now time.Time) bool {
 		return false
 	}
 
-	return now.UnixNano() > c.lastActiveTime+int64(c.maxIdleTime)
+	return now.UnixNano() > c.lastActiveTime.Load()+int64(c.maxIdleTime)
+}
+
+func (c *Connection) ConnectTime() time.Time {
+	return time.Unix(0, c.connectTime)
+}
+
+// LastActiveTime the time the last packet arrived
+func (c *Connection) LastActiveTime() time.Time {
+	return time.Unix(0, c.lastActiveTime.Load())
 }
 
 func (c *Connection) WithMaxIdleTime(maxIdleTime time.Duration) *Connection {
@@ -87,7 +202,28 @@ func (c *Connection) WithEndian(e binary.ByteOrder) *Connection {
 
 func (c *Connection) WithMaxLen(maxLen int) *Connection {
 	c.maxLen = maxLen
-	c.packBuff = make([]byte, c.maxLen)
+	if c.readLen == 0 {
+		c.releaseBuff()
+	}
+	return c
+}
+
+// WithReadBuffLen the read buffer starts with length and grows up to maxLen when a larger packet arrives
+func (c *Connection) WithReadBuffLen(length int) *Connection {
+	c.buffLen = length
+	return c
+}
+
+// WithShrinkAfter the grown read buffer shrinks back when no data arrives in idle
+func (c *Connection) WithShrinkAfter(idle time.Duration) *Connection {
+	c.shrinkAfter = idle
+	return c
+}
+
+// WithZeroCopy packets not longer than threshold reference the read buffer directly instead of copying,
+// the read buffer is reused only after these packets are released
+func (c *Connection) WithZeroCopy(threshold int) *Connection {
+	c.zeroCopy = threshold
 	return c
 }
 
@@ -101,120 +237,477 @@ func (c *Connection) WithBodyLenOffset(offset int) *Connection {
 	return c
 }
 
+// WithSchema the named fields of the header, Packet.Field decodes them
+func (c *Connection) WithSchema(schema *Schema) *Connection {
+	c.header.WithSchema(schema)
+	return c
+}
+
+// WithReadTimeout every read from the peer fails with Err_Read_Timeout after timeout
+func (c *Connection) WithReadTimeout(timeout time.Duration) *Connection {
+	c.readTimeout = timeout
+	return c
+}
+
+// WithWriteTimeout every write to the peer fails with Err_Write_Timeout after timeout
+func (c *Connection) WithWriteTimeout(timeout time.Duration) *Connection {
+	c.writeTimeout = timeout
+	return c
+}
+
+// WithHandshakeTimeout the secure handshake fails with Err_Handshake_Timeout after timeout
+func (c *Connection) WithHandshakeTimeout(timeout time.Duration) *Connection {
+	c.handshakeTimeout = timeout
+	return c
+}
+
 func (c *Connection) Write(data []byte) error {
-	if c.isClosed {
+	return c.send(data, time.Time{})
+}
+
+// WriteBody encode body and the header field values with the header of the connection, then write it,
+// it fails when len(body) overflows the length type instead of truncating the length like NewPacket
+func (c *Connection) WriteBody(body []byte, values ...FieldValue) error {
+	p, err := EncodePacket(body, c.header, values...)
+	if err != nil {
+		return err
+	}
+
+	return c.Write(p.Bytes())
+}
+
+// writeBody write the handshake frame before the secure channel is ready
+func (c *Connection) writeBody(body []byte, deadline time.Time) error {
+	p, err := EncodePacket(body, c.header)
+	if err != nil {
+		return err
+	}
+
+	return c.write(p.Bytes(), deadline)
+}
+
+// MaxBodyLen the longest body one packet carries, it is bound by maxLen, the length type and the secure overhead
+func (c *Connection) MaxBodyLen() int {
+	length := min(c.maxLen-c.header.headerLen, c.header.bodyLenType.Max())
+	if c.secure != nil {
+		length -= c.secure.Overhead()
+	}
+
+	return length
+}
+
+func (c *Connection) send(data []byte, deadline time.Time) error {
+	if c.isClosed.Load() {
 		return Err_Closed
 	}
 
-	_, err := c.conn.Write(data)
+	if c.tap != nil {
+		c.frames(data, func(header, body []byte) error {
+			c.tap.OnWrite(c, &Packet{Header: header, Body: body, header: c.header})
+			return nil
+		})
+	}
+
+	if c.secure == nil || !c.secure.isReady {
+		return c.write(data, deadline)
+	}
+
+	c.secure.locker.Lock()
+	defer c.secure.locker.Unlock()
+	buff, err := c.encrypt(data)
+	if err != nil {
+		return err
+	}
+
+	return c.write(buff, deadline)
+}
+
+// write the deadline is the earlier of deadline and the write timeout
+func (c *Connection) write(data []byte, deadline time.Time) error {
+	if c.header.checksum != nil {
+		var err error
+		if data, err = c.withChecksum(data); err != nil {
+			return err
+		}
+	}
+
+	c.writeLocker.Lock()
+	defer c.writeLocker.Unlock()
+	if c.writeTimeout > 0 {
+		if d := time.Now().Add(c.writeTimeout); deadline.IsZero() || d.Before(deadline) {
+			deadline = d
+		}
+	}
+	if !deadline.IsZero() || c.hasWriteDeadline {
+		c.conn.SetWriteDeadline(deadline)
+		c.hasWriteDeadline = !deadline.IsZero()
+	}
+
+	n, err := c.conn.Write(data)
+	c.counters.bytesWritten.Add(uint64(n))
+	if err == nil {
+		c.counters.writes.Add(1)
+		return nil
+	}
+
+	if isTimeout(err) {
+		c.counters.writeTimeouts.Add(1)
+		return Err_Write_Timeout
+	}
+
+	c.counters.ioErrors.Add(1)
 	return err
 }
 
+func isTimeout(err error) bool {
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
+
+// ReadLoop read packets into Packets until error, the channel is closed after the loop
 func (c *Connection) ReadLoop() {
+	packets := c.packetChan()
+	defer close(packets)
 	for {
 		packet, err := c.Read()
 		if err != nil {
 			break
 		}
 
-		c.packets <- packet
+		packets <- packet
 	}
 }
 
+// Err the error ended the last read, the errors of the net connection are classified by ErrorKind
+func (c *Connection) Err() error {
+	box, _ := c.err.Load().(errorBox)
+	return box.err
+}
+
 func (c *Connection) Packets() <-chan *Packet {
+	return c.packetChan()
+}
+
+func (c *Connection) packetChan() chan *Packet {
+	c.packetsOnce.Do(func() {
+		c.packets = make(chan *Packet, 1024)
+	})
 	return c.packets
 }
 
 func (c *Connection) Read() (*Packet, error) {
-	if c.packBuff == nil {
-		c.packBuff = make([]byte, c.maxLen)
+	packet, err := c.read()
+	if err != nil {
+		c.err.Store(errorBox{err: classify(err)})
 	}
-	var bodyLen = 0
-	var err error
+	if packet != nil && c.tap != nil {
+		c.tap.OnRead(c, packet)
+	}
+
+	return packet, err
+}
+
+func (c *Connection) read() (*Packet, error) {
 	for {
-		if bodyLen == 0 {
-			if c.readLen >= c.header.headerLen {
-				bodyLen, err = c.bodyLen(c.packBuff[c.header.bodyLenOffset : c.header.bodyLenOffset+c.header.bodyLengthLen])
-				if err != nil {
-					return nil, err
-				}
+		packet, err := c.next()
+		if err != nil || packet != nil {
+			return packet, err
+		}
+
+		c.prepare()
+		n, err := c.readConn()
+		if err != nil {
+			return nil, err
+		}
+
+		c.readLen += n
+	}
+}
+
+// readConn read from the net connection, the deadline is the earliest of the read timeout and the deadline
+// of ReadContext, the grown read buffer is shrunk when no data arrives in shrinkAfter while it is empty
+func (c *Connection) readConn() (int, error) {
+	deadline := c.readDeadline
+	if c.readTimeout > 0 {
+		if d := time.Now().Add(c.readTimeout); deadline.IsZero() || d.Before(deadline) {
+			deadline = d
+		}
+	}
+
+	var shrinkAt time.Time
+	if c.readLen == 0 && c.shrinkAfter > 0 && len(c.chunk.buff) > c.initLen() {
+		shrinkAt = time.Now().Add(c.shrinkAfter)
+		if deadline.IsZero() || shrinkAt.Before(deadline) {
+			deadline = shrinkAt
+		} else {
+			shrinkAt = time.Time{}
+		}
+	}
+
+	if !deadline.IsZero() || c.hasDeadline {
+		c.conn.SetReadDeadline(deadline)
+		c.hasDeadline = !deadline.IsZero()
+	}
+	if c.interrupted.Load() {
+		c.conn.SetReadDeadline(time.Unix(1, 0))
+		c.hasDeadline = true
+	}
+
+	n, err := c.conn.Read(c.chunk.buff[c.start+c.readLen:])
+	c.counters.bytesRead.Add(uint64(n))
+	if err == nil || err == io.EOF {
+		return n, err
+	}
+
+	if !isTimeout(err) {
+		c.counters.ioErrors.Add(1)
+		return n, err
+	}
+	if c.interrupted.Load() {
+		return n, err
+	}
+	if !shrinkAt.IsZero() {
+		c.releaseBuff()
+		c.prepare()
+		return 0, nil
+	}
+
+	c.counters.readTimeouts.Add(1)
+	return n, Err_Read_Timeout
+}
+
+// Feed append data read by an event loop and return the complete packets,
+// the read buffer is allocated only while a packet is incomplete
+func (c *Connection) Feed(data []byte) ([]*Packet, error) {
+	var packets []*Packet
+	for len(data) > 0 {
+		c.prepare()
+		n := copy(c.chunk.buff[c.start+c.readLen:], data)
+		c.readLen += n
+		data = data[n:]
+		for {
+			packet, err := c.next()
+			if err != nil {
+				return packets, err
 			}
+			if packet == nil {
+				break
+			}
+
+			if c.tap != nil {
+				c.tap.OnRead(c, packet)
+			}
+			packets = append(packets, packet)
 		}
+	}
+
+	if c.readLen == 0 {
+		c.releaseBuff()
+	}
+
+	return packets, nil
+}
+
+func (c *Connection) initLen() int {
+	length := c.buffLen
+	if length < c.header.headerLen {
+		length = c.header.headerLen
+	}
+	if length > c.maxLen || length <= 0 {
+		length = c.maxLen
+	}
+
+	return length
+}
+
+// prepare make room at the tail of the read buffer
+func (c *Connection) prepare() {
+	if c.chunk == nil {
+		c.chunk = newChunk(c.initLen())
+		c.start = 0
+		return
+	}
+
+	if c.start+c.readLen < len(c.chunk.buff) {
+		return
+	}
+
+	size := len(c.chunk.buff)
+	if c.start == 0 {
+		size *= 2
+	}
+	c.reserve(size)
+}
+
+// reserve make the read buffer hold at least length bytes from the unread bytes, up to maxLen,
+// the unread bytes are moved to a new buffer when zero copy packets still reference the current one
+func (c *Connection) reserve(length int) {
+	if length > c.maxLen {
+		length = c.maxLen
+	}
+	if c.start+length <= len(c.chunk.buff) {
+		return
+	}
+
+	if length <= len(c.chunk.buff) && !c.chunk.shared() {
+		copy(c.chunk.buff, c.chunk.buff[c.start:c.start+c.readLen])
+		c.start = 0
+		return
+	}
+
+	size := len(c.chunk.buff)
+	if size < length {
+		size = min(max(size*2, length), c.maxLen)
+	}
+
+	old := c.chunk
+	c.chunk = newChunk(size)
+	copy(c.chunk.buff, old.buff[c.start:c.start+c.readLen])
+	old.release()
+	c.start = 0
+}
+
+func (c *Connection) releaseBuff() {
+	if c.chunk == nil {
+		return
+	}
 
-		if c.readLen >= c.header.headerLen+bodyLen {
-			return c.copyBuff(bodyLen), nil
+	c.chunk.release()
+	c.chunk = nil
+	c.start = 0
+}
+
+// next return the first complete packet in the read buffer, nil if the packet is incomplete,
+// the packet whose checksum mismatches is dropped unless the action closes the connection
+func (c *Connection) next() (*Packet, error) {
+	for {
+		packet, err := c.nextPacket()
+		if err != nil || packet == nil || c.header.checksum == nil {
+			return packet, err
 		}
 
-		if c.readLen >= c.maxLen {
-			return nil, Err_Packet_Out_Range
+		ok, err := c.checkSum(packet)
+		if err != nil {
+			return nil, err
 		}
+		if !ok {
+			continue
+		}
+		if c.secure != nil && c.secure.isReady {
+			if err := c.decrypt(packet); err != nil {
+				return nil, err
+			}
+		}
+
+		return packet, nil
+	}
+}
 
-		n, err := c.conn.Read(c.packBuff[c.readLen:])
+// nextPacket the packet longer than maxLen is rejected as soon as the header is read
+func (c *Connection) nextPacket() (*Packet, error) {
+	if c.readLen >= c.header.headerLen {
+		buff := c.chunk.buff[c.start : c.start+c.readLen]
+		bodyLen, err := c.header.Decode(buff)
 		if err != nil {
 			return nil, err
 		}
+		if bodyLen > c.maxLen-c.header.headerLen {
+			return nil, Err_Packet_Out_Range
+		}
 
-		c.readLen += n
+		length := c.header.headerLen + bodyLen
+
+		if c.readLen >= length {
+			packet := c.copyBuff(bodyLen)
+			if c.header.checksum == nil && c.secure != nil && c.secure.isReady {
+				if err := c.decrypt(packet); err != nil {
+					return nil, err
+				}
+			}
+
+			return packet, nil
+		}
+
+		c.reserve(length)
+	}
+
+	if c.readLen >= c.maxLen {
+		return nil, Err_Packet_Out_Range
 	}
+
+	return nil, nil
 }
 
 func (c *Connection) copyBuff(bodyLen int) *Packet {
 	buffLen := c.header.headerLen + bodyLen
-	p := &Packet{Body: make([]byte, bodyLen), Header: make([]byte, c.header.headerLen)}
-	copy(p.Header, c.packBuff[:c.header.headerLen])
-	copy(p.Body, c.packBuff[c.header.headerLen:])
-	copy(c.packBuff, c.packBuff[buffLen:c.readLen])
+	raw := c.chunk.buff[c.start : c.start+buffLen]
+	var p *Packet
+	if buffLen <= c.zeroCopy {
+		p = newPacket(c.chunk.retain(), raw, c.header)
+	} else {
+		pc := newChunk(buffLen)
+		copy(pc.buff, raw)
+		p = newPacket(pc, pc.buff, c.header)
+	}
+
+	c.start += buffLen
 	c.readLen -= buffLen
+	if c.readLen == 0 && !c.chunk.shared() {
+		c.start = 0
+	}
 
-	c.lastActiveTime = time.Now().UnixNano()
+	c.lastActiveTime.Store(time.Now().UnixNano())
+	c.counters.packetsRead.Add(1)
 	return p
 }
 
-func (c *Connection) bodyLen(data []byte) (int, error) {
-	buffer := bytes.NewBuffer(data)
-	switch c.header.bodyLenType {
-	case Len_Type_Int8:
-		var l int8
-		err := binary.Read(buffer, c.header.endian, &l)
-		return int(l), err
-	case Len_Type_Int16:
-		var l int16
-		err := binary.Read(buffer, c.header.endian, &l)
-		return int(l), err
-	case Len_Type_Int32:
-		var l int32
-		err := binary.Read(buffer, c.header.endian, &l)
-		return int(l), err
-	case Len_Type_Int64:
-		var l int64
-		err := binary.Read(buffer, c.header.endian, &l)
-		return int(l), err
-	case Len_Type_UInt8:
-		var l uint8
-		err := binary.Read(buffer, c.header.endian, &l)
-		return int(l), err
-	case Len_Type_UInt16:
-		var l uint16
-		err := binary.Read(buffer, c.header.endian, &l)
-		return int(l), err
-	case Len_Type_UInt32:
-		var l uint32
-		err := binary.Read(buffer, c.header.endian, &l)
-		return int(l), err
-	case Len_Type_UInt64:
-		var l uint64
-		err := binary.Read(buffer, c.header.endian, &l)
-		return int(l), err
-	}
-
-	return 0, Err_Unkown_Body_Len_Type
+// frames call fn with the header and body of every packet in data
+func (c *Connection) frames(data []byte, fn func(header, body []byte) error) error {
+	headerLen := c.header.headerLen
+	for len(data) > 0 {
+		if len(data) < headerLen {
+			return Err_Packet_Out_Range
+		}
+
+		bodyLen, err := c.header.Decode(data)
+		if err != nil {
+			return err
+		}
+		if bodyLen > len(data)-headerLen {
+			return Err_Packet_Out_Range
+		}
+
+		if err := fn(data[:headerLen:headerLen], data[headerLen:headerLen+bodyLen:headerLen+bodyLen]); err != nil {
+			return err
+		}
+		data = data[headerLen+bodyLen:]
+	}
+
+	return nil
 }
 
+// Close the connection, the close reason is the error ended the last read
 func (c *Connection) Close() error {
-	if c.isClosed {
+	return c.CloseWith(nil)
+}
+
+// CloseWith close the connection for reason, the close callbacks can get it by CloseReason
+func (c *Connection) CloseWith(reason error) error {
+	if !c.isClosed.CompareAndSwap(false, true) {
 		return Err_Closed
 	}
 
-	c.isClosed = true
+	if reason == nil {
+		reason = c.Err()
+	}
+	c.reason.Store(errorBox{err: classify(reason)})
+	c.cancel()
+	defer c.cleanup()
 	return c.conn.Close()
 }
+
+// CloseReason the reason the connection closed, nil when it is closed locally without an error
+func (c *Connection) CloseReason() error {
+	box, _ := c.reason.Load().(errorBox)
+	return box.err
+}
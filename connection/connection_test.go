@@ -0,0 +1,114 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// failConn fail every read with err
+type failConn struct {
+	streamConn
+	err error
+}
+
+func (c *failConn) Read([]byte) (int, error) { return 0, c.err }
+
+func TestReadErrorRedial(t *testing.T) {
+	custom := errors.New("custom")
+	tests := []struct {
+		name   string
+		err    error
+		expect error
+	}{
+		{"eof", io.EOF, Err_EOF},
+		{"custom", custom, custom},
+		{"closed", net.ErrClosed, Err_IO},
+		{"eof again", io.ErrUnexpectedEOF, Err_EOF},
+	}
+
+	// the errors of different types are stored by one connection after every redial
+	c := NewConnection(1, &failConn{err: io.EOF})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.WithConn(&failConn{err: tt.err})
+			if c.Err() != nil {
+				t.Fatal(c.Err())
+			}
+			if _, err := c.Read(); !errors.Is(err, tt.err) {
+				t.Fatal(err)
+			}
+			if err := c.Err(); !errors.Is(err, tt.expect) {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	a, b := net.Pipe()
+	defer b.Close()
+	c := NewConnection(1, a).WithReadTimeout(100 * time.Millisecond).WithWriteTimeout(100 * time.Millisecond)
+	peer := NewConnection(2, b)
+	go peer.Write(NewPacket([]byte("hi"), peer.Header()).Bytes())
+	if p, err := c.Read(); err != nil || string(p.Body) != "hi" {
+		t.Fatal(p, err)
+	}
+
+	start := time.Now()
+	if _, err := c.Read(); err != Err_Read_Timeout || time.Since(start) > time.Second {
+		t.Fatal(err)
+	}
+	if err := c.Write(NewPacket([]byte("x"), c.Header()).Bytes()); err != Err_Write_Timeout {
+		t.Fatal(err)
+	}
+	if stats := c.Stats(); stats.ReadTimeouts != 1 || stats.WriteTimeouts != 1 || stats.PacketsRead != 1 {
+		t.Fatal(stats)
+	}
+	if c.Err() != Err_Read_Timeout {
+		t.Fatal(c.Err())
+	}
+}
+
+func TestHandshakeTimeout(t *testing.T) {
+	a, b := net.Pipe()
+	defer b.Close()
+	c := NewConnection(1, a).WithSecure(NewSecure(Cipher_AES_128_GCM, true)).WithHandshakeTimeout(100 * time.Millisecond)
+	if err := c.Handshake(); err != Err_Handshake_Timeout {
+		t.Fatal(err)
+	}
+}
+
+func TestReadContext(t *testing.T) {
+	a, b := net.Pipe()
+	writer := NewConnection(1, a)
+	c := NewConnection(2, b)
+	defer writer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.ReadContext(ctx); err != context.DeadlineExceeded && err != Err_Read_Timeout {
+		t.Fatal(err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(30*time.Millisecond, cancel)
+	if _, err := c.ReadContext(ctx); err != context.Canceled {
+		t.Fatal(err)
+	}
+
+	go writer.Write(NewPacket([]byte("x"), writer.Header()).Bytes())
+	if p, err := c.Read(); err != nil || string(p.Body) != "x" {
+		t.Fatal(p, err)
+	}
+
+	// the context of the connection is cancelled when it is closed
+	done := c.Context()
+	c.Close()
+	if done.Err() == nil {
+		t.Fatal("context is not cancelled")
+	}
+}
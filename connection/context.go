@@ -0,0 +1,79 @@
+package connection
+
+import (
+	"context"
+	"time"
+)
+
+// WithContext the context of the connection is derived from parent and cancelled when the connection is closed
+func (c *Connection) WithContext(parent context.Context) *Connection {
+	if c.cancel != nil {
+		c.cancel()
+	}
+
+	c.parent = parent
+	c.ctx, c.cancel = context.WithCancel(parent)
+	return c
+}
+
+func (c *Connection) Context() context.Context {
+	return c.ctx
+}
+
+// ReadContext read a packet until the deadline of ctx or ctx is cancelled,
+// it must not be called concurrently with Read or ReadLoop
+func (c *Connection) ReadContext(ctx context.Context) (*Packet, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		c.readDeadline = deadline
+		defer func() {
+			c.readDeadline = time.Time{}
+		}()
+	}
+
+	done := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		defer close(done)
+		c.interrupted.Store(true)
+		c.conn.SetReadDeadline(time.Unix(1, 0))
+	})
+	defer func() {
+		if !stop() {
+			<-done
+			c.interrupted.Store(false)
+			c.hasDeadline = true
+		}
+	}()
+
+	packet, err := c.Read()
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	return packet, err
+}
+
+// WriteContext write data until the deadline of ctx or ctx is cancelled
+func (c *Connection) WriteContext(ctx context.Context, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	deadline, _ := ctx.Deadline()
+	stop := context.AfterFunc(ctx, func() {
+		c.conn.SetWriteDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+
+	if err := c.send(data, deadline); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+
+	return nil
+}
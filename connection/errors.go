@@ -0,0 +1,103 @@
+package connection
+
+import (
+	"errors"
+	"io"
+	"net"
+)
+
+// ErrorKind is the category of an error, errors.Is(err, kind) reports whether err is of the kind
+type ErrorKind byte
+
+const (
+	Err_Protocol     ErrorKind = 1 // malformed, undecryptable or unexpected data from the peer
+	Err_Oversize     ErrorKind = 2 // packet exceeds the max length
+	Err_Idle_Timeout ErrorKind = 3 // no data arrives in time
+	Err_Shutdown     ErrorKind = 4 // the server or client is shutdown
+	Err_Kicked       ErrorKind = 5 // the connection is kicked by the server
+	Err_EOF          ErrorKind = 6 // the peer closed the connection
+	Err_IO           ErrorKind = 7 // read or write failure of the net connection
+)
+
+var kindNames = map[ErrorKind]string{
+	Err_Protocol:     "protocol error",
+	Err_Oversize:     "oversize packet",
+	Err_Idle_Timeout: "idle timeout",
+	Err_Shutdown:     "shutdown",
+	Err_Kicked:       "kicked",
+	Err_EOF:          "peer closed",
+	Err_IO:           "io error",
+}
+
+func (k ErrorKind) Error() string {
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+
+	return "unkown error"
+}
+
+// KindName the name of the kind, it is used by the logger to add the kind field
+func (k ErrorKind) KindName() string {
+	return k.Error()
+}
+
+// Error is an error with its kind, both errors.Is(err, kind) and errors.Is(err, cause) are true
+type Error struct {
+	Kind  ErrorKind
+	Cause error
+}
+
+// NewError create the error of kind, the message is the message of cause
+func NewError(kind ErrorKind, cause error) *Error {
+	return &Error{Kind: kind, Cause: cause}
+}
+
+func (e *Error) Error() string {
+	if e.Cause == nil {
+		return e.Kind.Error()
+	}
+
+	return e.Cause.Error()
+}
+
+func (e *Error) Unwrap() []error {
+	if e.Cause == nil {
+		return []error{e.Kind}
+	}
+
+	return []error{e.Kind, e.Cause}
+}
+
+// KindOf the kind of err, 0 when err is not classified
+func KindOf(err error) ErrorKind {
+	var kind ErrorKind
+	if errors.As(err, &kind) {
+		return kind
+	}
+
+	return 0
+}
+
+// classify give the kind to the errors of the net connection
+func classify(err error) error {
+	if err == nil || KindOf(err) != 0 {
+		return err
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return NewError(Err_EOF, err)
+	}
+
+	var ne net.Error
+	if errors.As(err, &ne) || errors.Is(err, net.ErrClosed) {
+		return NewError(Err_IO, err)
+	}
+
+	return err
+}
+
+// errorBox keeps the concrete type stored in atomic.Value the same
+type errorBox struct {
+	err error
+}
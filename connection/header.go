@@ -1,16 +1,64 @@
 package connection
 
 import (
-	"bytes"
 	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
 )
 
+var Err_Invalid_Header = errors.New("invalid header layout")
+var Err_Body_Len_Overflow = NewError(Err_Oversize, errors.New("body length overflows the length type"))
+var Err_Negative_Body_Len = NewError(Err_Protocol, errors.New("negative body length"))
+
+// Width the bytes of the length field, 0 when the type is unkown
+func (t LenType) Width() int {
+	switch t {
+	case Len_Type_Int8, Len_Type_UInt8:
+		return 1
+	case Len_Type_Int16, Len_Type_UInt16:
+		return 2
+	case Len_Type_Int32, Len_Type_UInt32:
+		return 4
+	case Len_Type_Int64, Len_Type_UInt64:
+		return 8
+	default:
+		return 0
+	}
+}
+
+// Max the max body length the type can hold
+func (t LenType) Max() int {
+	switch t {
+	case Len_Type_Int8:
+		return math.MaxInt8
+	case Len_Type_Int16:
+		return math.MaxInt16
+	case Len_Type_Int32:
+		return math.MaxInt32
+	case Len_Type_UInt8:
+		return math.MaxUint8
+	case Len_Type_UInt16:
+		return math.MaxUint16
+	case Len_Type_UInt32:
+		return math.MaxUint32
+	case Len_Type_Int64, Len_Type_UInt64:
+		return math.MaxInt
+	default:
+		return 0
+	}
+}
+
 type Header struct {
 	headerLen     int
 	bodyLenOffset int
 	bodyLengthLen int
 	bodyLenType   LenType
 	endian        binary.ByteOrder
+	schema        *Schema
+	checksum      *checksum
+	hasKind       bool
+	kindOffset    int
 }
 
 func NewHeader() *Header {
@@ -19,7 +67,7 @@ func NewHeader() *Header {
 
 func (h *Header) WithBodyLenType(t LenType) *Header {
 	h.bodyLenType = t
-	h.bodyLengthLen = h._len(t)
+	h.bodyLengthLen = t.Width()
 	return h
 }
 
@@ -38,86 +86,165 @@ func (h *Header) WithEndian(endian binary.ByteOrder) *Header {
 	return h
 }
 
+// WithSchema the named fields of the header, e.g. version and command
+func (h *Header) WithSchema(schema *Schema) *Header {
+	h.schema = schema
+	return h
+}
+
+func (h *Header) Schema() *Schema {
+	return h.schema
+}
+
 func (h *Header) BodyLenOffset() int {
 	return h.bodyLenOffset
 }
 
-func (h *Header) _len(t LenType) int {
-	switch t {
-	case Len_Type_Int8, Len_Type_UInt8:
-		return 1
-	case Len_Type_Int16, Len_Type_UInt16:
-		return 2
-	case Len_Type_Int32, Len_Type_UInt32:
-		return 4
-	case Len_Type_Int64, Len_Type_UInt64:
-		return 8
-	default:
-		return 4
+func (h *Header) HeaderLen() int {
+	return h.headerLen
+}
+
+func (h *Header) BodyLenType() LenType {
+	return h.bodyLenType
+}
+
+// Validate reject the layout the length field does not fit in, errors.Is(err, Err_Invalid_Header) is true
+func (h *Header) Validate() error {
+	if h.bodyLengthLen == 0 {
+		return fmt.Errorf("%w: unkown body length type %d", Err_Invalid_Header, h.bodyLenType)
+	}
+	if h.endian == nil {
+		return fmt.Errorf("%w: endian is nil", Err_Invalid_Header)
+	}
+	if h.headerLen <= 0 {
+		return fmt.Errorf("%w: header length %d is not positive", Err_Invalid_Header, h.headerLen)
+	}
+	if h.bodyLenOffset < 0 {
+		return fmt.Errorf("%w: body length offset %d is negative", Err_Invalid_Header, h.bodyLenOffset)
 	}
+	if h.bodyLenOffset+h.bodyLengthLen > h.headerLen {
+		return fmt.Errorf("%w: body length offset(%d) + width(%d) > header length(%d)", Err_Invalid_Header, h.bodyLenOffset, h.bodyLengthLen, h.headerLen)
+	}
+	if h.schema != nil {
+		if err := h.schema.validate(h); err != nil {
+			return err
+		}
+	}
+	if h.checksum != nil {
+		if err := h.validateChecksum(); err != nil {
+			return err
+		}
+	}
+	if h.hasKind {
+		return h.validateKind()
+	}
+
+	return nil
 }
 
-func (h *Header) Header(bodyLen int) []byte {
-	var buffer bytes.Buffer
+// Header the header of bodyLen and the field values, the length is truncated when it overflows the length type,
+// the unkown fields and the overflowed values are skipped, use Encode to check them
+func (h *Header) Header(bodyLen int, values ...FieldValue) []byte {
 	headers := make([]byte, h.headerLen)
+	h.putBodyLen(headers, bodyLen)
+	h.putFields(headers, values, false)
+	return headers
+}
+
+// Encode the header of bodyLen and the field values, it fails when the layout is invalid, bodyLen is negative
+// or overflows the length type, a field is unkown or its value overflows the field
+func (h *Header) Encode(bodyLen int, values ...FieldValue) ([]byte, error) {
+	if err := h.Validate(); err != nil {
+		return nil, err
+	}
+
+	headers := make([]byte, h.headerLen)
+	if err := h.PutBodyLen(headers, bodyLen); err != nil {
+		return nil, err
+	}
+	if err := h.putFields(headers, values, true); err != nil {
+		return nil, err
+	}
+
+	return headers, nil
+}
+
+// PutBodyLen write bodyLen into the length field of headers
+func (h *Header) PutBodyLen(headers []byte, bodyLen int) error {
+	if bodyLen < 0 {
+		return Err_Negative_Body_Len
+	}
+	if bodyLen > h.bodyLenType.Max() {
+		return fmt.Errorf("%w: %d > %d", Err_Body_Len_Overflow, bodyLen, h.bodyLenType.Max())
+	}
+	if len(headers) < h.headerLen {
+		return Err_Packet_Out_Range
+	}
+
+	h.putBodyLen(headers, bodyLen)
+	return nil
+}
+
+func (h *Header) putBodyLen(headers []byte, bodyLen int) {
+	if h.bodyLenOffset < 0 || h.bodyLenOffset+h.bodyLengthLen > len(headers) || h.endian == nil {
+		return
+	}
+
+	field := headers[h.bodyLenOffset : h.bodyLenOffset+h.bodyLengthLen]
+	switch h.bodyLengthLen {
+	case 1:
+		field[0] = byte(bodyLen)
+	case 2:
+		h.endian.PutUint16(field, uint16(bodyLen))
+	case 4:
+		h.endian.PutUint32(field, uint32(bodyLen))
+	case 8:
+		h.endian.PutUint64(field, uint64(bodyLen))
+	}
+}
+
+// Decode the body length in headers, it fails when the layout is invalid, the length is negative or overflows int
+func (h *Header) Decode(headers []byte) (int, error) {
+	if h.bodyLenOffset < 0 || h.bodyLengthLen == 0 || h.bodyLenOffset+h.bodyLengthLen > h.headerLen || h.endian == nil {
+		return 0, h.Validate()
+	}
+	if len(headers) < h.headerLen {
+		return 0, Err_Packet_Out_Range
+	}
+
+	field := headers[h.bodyLenOffset : h.bodyLenOffset+h.bodyLengthLen]
+	var l int64
 	switch h.bodyLenType {
 	case Len_Type_Int8:
-		binary.Write(&buffer, h.endian, int8(bodyLen))
-		headers[h.bodyLenOffset] = buffer.Bytes()[0]
+		l = int64(int8(field[0]))
 	case Len_Type_Int16:
-		var buffer bytes.Buffer
-		binary.Write(&buffer, h.endian, int16(bodyLen))
-		bs := buffer.Bytes()
-		headers[h.bodyLenOffset] = bs[0]
-		headers[h.bodyLenOffset+1] = bs[1]
+		l = int64(int16(h.endian.Uint16(field)))
 	case Len_Type_Int32:
-		var buffer bytes.Buffer
-		binary.Write(&buffer, h.endian, int32(bodyLen))
-		bs := buffer.Bytes()
-		headers[h.bodyLenOffset] = bs[0]
-		headers[h.bodyLenOffset+1] = bs[1]
-		headers[h.bodyLenOffset+2] = bs[2]
-		headers[h.bodyLenOffset+3] = bs[3]
+		l = int64(int32(h.endian.Uint32(field)))
 	case Len_Type_Int64:
-		var buffer bytes.Buffer
-		binary.Write(&buffer, h.endian, int64(bodyLen))
-		bs := buffer.Bytes()
-		headers[h.bodyLenOffset] = bs[0]
-		headers[h.bodyLenOffset+1] = bs[1]
-		headers[h.bodyLenOffset+2] = bs[2]
-		headers[h.bodyLenOffset+3] = bs[3]
-		headers[h.bodyLenOffset+4] = bs[4]
-		headers[h.bodyLenOffset+5] = bs[5]
-		headers[h.bodyLenOffset+6] = bs[6]
-		headers[h.bodyLenOffset+7] = bs[7]
+		l = int64(h.endian.Uint64(field))
 	case Len_Type_UInt8:
-		headers[h.bodyLenOffset] = byte(bodyLen)
+		l = int64(field[0])
 	case Len_Type_UInt16:
-		var buffer bytes.Buffer
-		binary.Write(&buffer, h.endian, uint16(bodyLen))
-		bs := buffer.Bytes()
-		headers[h.bodyLenOffset] = bs[0]
-		headers[h.bodyLenOffset+1] = bs[1]
+		l = int64(h.endian.Uint16(field))
 	case Len_Type_UInt32:
-		var buffer bytes.Buffer
-		binary.Write(&buffer, h.endian, uint32(bodyLen))
-		bs := buffer.Bytes()
-		headers[h.bodyLenOffset] = bs[0]
-		headers[h.bodyLenOffset+1] = bs[1]
-		headers[h.bodyLenOffset+2] = bs[2]
-		headers[h.bodyLenOffset+3] = bs[3]
+		l = int64(h.endian.Uint32(field))
 	case Len_Type_UInt64:
-		var buffer bytes.Buffer
-		binary.Write(&buffer, h.endian, uint64(bodyLen))
-		bs := buffer.Bytes()
-		headers[h.bodyLenOffset] = bs[0]
-		headers[h.bodyLenOffset+1] = bs[1]
-		headers[h.bodyLenOffset+2] = bs[2]
-		headers[h.bodyLenOffset+3] = bs[3]
-		headers[h.bodyLenOffset+4] = bs[4]
-		headers[h.bodyLenOffset+5] = bs[5]
-		headers[h.bodyLenOffset+6] = bs[6]
-		headers[h.bodyLenOffset+7] = bs[7]
+		u := h.endian.Uint64(field)
+		if u > math.MaxInt64 {
+			return 0, Err_Body_Len_Overflow
+		}
+		l = int64(u)
+	default:
+		return 0, Err_Unkown_Body_Len_Type
 	}
-	return headers
+
+	if l < 0 {
+		return 0, Err_Negative_Body_Len
+	}
+	if l > int64(math.MaxInt) {
+		return 0, Err_Body_Len_Overflow
+	}
+
+	return int(l), nil
 }
@@ -0,0 +1,119 @@
+package connection
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestHeaderValidate(t *testing.T) {
+	h := NewHeader().WithHeaderLen(4).WithBodyLenOffset(2).WithBodyLenType(Len_Type_Int32)
+	if err := h.Validate(); !errors.Is(err, Err_Invalid_Header) {
+		t.Fatal(err)
+	}
+	if _, err := h.Encode(1); !errors.Is(err, Err_Invalid_Header) {
+		t.Fatal(err)
+	}
+	if _, err := h.Decode(make([]byte, 4)); !errors.Is(err, Err_Invalid_Header) {
+		t.Fatal(err)
+	}
+	_ = h.Header(10)
+
+	if err := NewHeader().WithBodyLenType(LenType(9)).Validate(); !errors.Is(err, Err_Invalid_Header) {
+		t.Fatal(err)
+	}
+	if err := NewConnection(1, nil).WithMaxLen(4).Validate(); !errors.Is(err, Err_Invalid_Header) {
+		t.Fatal(err)
+	}
+}
+
+func TestHeaderEncode(t *testing.T) {
+	u8 := NewHeader().WithHeaderLen(1).WithBodyLenType(Len_Type_UInt8)
+	if _, err := u8.Encode(300); !errors.Is(err, Err_Body_Len_Overflow) || KindOf(err) != Err_Oversize {
+		t.Fatal(err)
+	}
+	if _, err := u8.Encode(-1); !errors.Is(err, Err_Negative_Body_Len) {
+		t.Fatal(err)
+	}
+	if b, err := u8.Encode(255); err != nil || b[0] != 255 {
+		t.Fatal(b, err)
+	}
+	if _, err := EncodePacket(make([]byte, 256), u8); !errors.Is(err, Err_Body_Len_Overflow) {
+		t.Fatal(err)
+	}
+
+	i16 := NewHeader().WithHeaderLen(3).WithBodyLenOffset(1).WithBodyLenType(Len_Type_Int16).WithEndian(binary.LittleEndian)
+	b, _ := i16.Encode(32767)
+	if n, err := i16.Decode(b); n != 32767 || err != nil {
+		t.Fatal(n, err)
+	}
+}
+
+func TestHeaderDecode(t *testing.T) {
+	i16 := NewHeader().WithHeaderLen(3).WithBodyLenOffset(1).WithBodyLenType(Len_Type_Int16)
+	if _, err := i16.Decode([]byte{0, 0xff, 0xff}); !errors.Is(err, Err_Negative_Body_Len) {
+		t.Fatal(err)
+	}
+	u64 := NewHeader().WithHeaderLen(8).WithBodyLenType(Len_Type_UInt64)
+	if _, err := u64.Decode([]byte{0xff, 0, 0, 0, 0, 0, 0, 0}); !errors.Is(err, Err_Body_Len_Overflow) {
+		t.Fatal(err)
+	}
+	i64 := NewHeader().WithHeaderLen(8).WithBodyLenType(Len_Type_Int64)
+	if _, err := i64.Decode([]byte{0x7f, 0xff, 0, 0, 0, 0, 0, 0}); err != nil {
+		t.Fatal(err)
+	}
+
+	a, z := net.Pipe()
+	defer z.Close()
+	go z.Write([]byte{0xff, 0xff, 0xff, 0xfe, 1, 2, 3})
+	if _, err := NewConnection(1, a).Read(); !errors.Is(err, Err_Negative_Body_Len) || KindOf(err) != Err_Protocol {
+		t.Fatal(err)
+	}
+
+	a, z = net.Pipe()
+	defer z.Close()
+	go z.Write([]byte{0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	if _, err := NewConnection(1, a).WithBodyLenType(Len_Type_Int64).WithHeaderLen(8).Read(); !errors.Is(err, Err_Packet_Out_Range) {
+		t.Fatal(err)
+	}
+}
+
+func TestWriteBody(t *testing.T) {
+	a, z := net.Pipe()
+	defer z.Close()
+	c := NewConnection(1, a).WithHeaderLen(1).WithBodyLenType(Len_Type_Int8)
+	if err := c.WriteBody(make([]byte, 128)); !errors.Is(err, Err_Body_Len_Overflow) {
+		t.Fatal(err)
+	}
+
+	go c.WriteBody([]byte("abc"))
+	buff := make([]byte, 4)
+	if n, err := z.Read(buff); n != 4 || err != nil || buff[0] != 3 || string(buff[1:]) != "abc" {
+		t.Fatal(n, err, buff)
+	}
+}
+
+func TestHeaderKind(t *testing.T) {
+	if err := NewHeader().WithKind(1).Validate(); !errors.Is(err, Err_Invalid_Header) {
+		t.Fatal(err)
+	}
+	if err := NewHeader().WithHeaderLen(6).WithKind(4).WithChecksum(Checksum_CRC32, 2).Validate(); !errors.Is(err, Err_Invalid_Header) {
+		t.Fatal(err)
+	}
+	if _, err := EncodeFrame(Kind_Stream, nil, NewHeader()); !errors.Is(err, Err_No_Kind) {
+		t.Fatal(err)
+	}
+
+	h := NewHeader().WithHeaderLen(5).WithKind(4)
+	if err := h.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	p, err := EncodeFrame(Kind_Mux, []byte("abc"), h)
+	if err != nil || p.Kind() != Kind_Mux || p.Header[4] != byte(Kind_Mux) {
+		t.Fatal(p, err)
+	}
+	if p := NewPacket([]byte("abc"), h); p.Kind() != Kind_Data {
+		t.Fatal(p.Kind())
+	}
+}
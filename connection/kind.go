@@ -0,0 +1,103 @@
+package connection
+
+import (
+	"errors"
+	"fmt"
+)
+
+var Err_No_Kind = errors.New("header has no kind field")
+
+// Kind is the byte of the header tells the frames of the stream, mux, push and resume packages from the
+// application packets, the body of the application packets is never inspected to route them
+type Kind byte
+
+const (
+	Kind_Data   Kind = 0 // the application packet
+	Kind_Stream Kind = 1
+	Kind_Mux    Kind = 2
+	Kind_Push   Kind = 3
+	Kind_Resume Kind = 4
+)
+
+// WithKind reserve the byte at offset of the header for the kind, negative offset disables it,
+// both peers must use the same offset
+func (h *Header) WithKind(offset int) *Header {
+	h.hasKind = offset >= 0
+	h.kindOffset = offset
+	return h
+}
+
+// HasKind report whether the header has the kind field
+func (h *Header) HasKind() bool {
+	return h.hasKind
+}
+
+func (h *Header) KindOffset() int {
+	return h.kindOffset
+}
+
+func (h *Header) validateKind() error {
+	if h.kindOffset >= h.headerLen {
+		return fmt.Errorf("%w: kind offset(%d) >= header length(%d)", Err_Invalid_Header, h.kindOffset, h.headerLen)
+	}
+	if overlap(h.kindOffset, 1, h.bodyLenOffset, h.bodyLengthLen) {
+		return fmt.Errorf("%w: kind overlaps the body length", Err_Invalid_Header)
+	}
+	if h.schema != nil {
+		for _, f := range h.schema.fields {
+			if overlap(h.kindOffset, 1, f.Offset, f.Width) {
+				return fmt.Errorf("%w: kind overlaps field %s", Err_Invalid_Header, f.Name)
+			}
+		}
+	}
+	if cs := h.checksum; cs != nil && overlap(h.kindOffset, 1, cs.offset, cs.t.Width()) {
+		return fmt.Errorf("%w: kind overlaps the checksum", Err_Invalid_Header)
+	}
+
+	return nil
+}
+
+// Kind the kind of the packet, Kind_Data when the header has no kind field
+func (p *Packet) Kind() Kind {
+	if p.header == nil || !p.header.hasKind || p.header.kindOffset >= len(p.Header) {
+		return Kind_Data
+	}
+
+	return Kind(p.Header[p.header.kindOffset])
+}
+
+// HasKind report whether the header of the packet has the kind field
+func (p *Packet) HasKind() bool {
+	return p.header != nil && p.header.hasKind
+}
+
+// EncodeFrame the packet of body with kind in the header, it fails with Err_No_Kind when the header has no kind field
+func EncodeFrame(kind Kind, body []byte, header *Header) (*Packet, error) {
+	if !header.hasKind {
+		return nil, Err_No_Kind
+	}
+
+	p, err := EncodePacket(body, header)
+	if err != nil {
+		return nil, err
+	}
+
+	p.Header[header.kindOffset] = byte(kind)
+	return p, nil
+}
+
+// WithKind reserve the byte at offset of the header for the kind
+func (c *Connection) WithKind(offset int) *Connection {
+	c.header.WithKind(offset)
+	return c
+}
+
+// WriteFrame encode body with kind in the header and write it
+func (c *Connection) WriteFrame(kind Kind, body []byte) error {
+	p, err := EncodeFrame(kind, body, c.header)
+	if err != nil {
+		return err
+	}
+
+	return c.Write(p.Bytes())
+}
@@ -0,0 +1,248 @@
+package connection
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+var Err_Negotiate = NewError(Err_Protocol, errors.New("malformed negotiation frame"))
+var Err_Version = NewError(Err_Protocol, errors.New("no common protocol version"))
+var Err_Feature = NewError(Err_Protocol, errors.New("required feature is not supported by the peer"))
+
+// Feature is the bit flags of the optional features agreed by both peers,
+// only Feature_Encryption is defined by the library, the other bits are defined by the applications
+type Feature uint32
+
+const (
+	Feature_Encryption Feature = 1 << 1 // the secure channel, it is added when the secure channel is configured
+)
+
+var featureNames = map[Feature]string{Feature_Encryption: "encryption"}
+
+func (f Feature) Has(feature Feature) bool {
+	return f&feature == feature
+}
+
+// String the names of the known features joined by "|", the application bits are shown in hex
+func (f Feature) String() string {
+	var names []string
+	rest := f
+	for bit := Feature(1); bit != 0; bit <<= 1 {
+		if name, ok := featureNames[bit]; ok && f.Has(bit) {
+			names = append(names, name)
+			rest &^= bit
+		}
+	}
+	if rest != 0 {
+		names = append(names, fmt.Sprintf("0x%x", uint32(rest)))
+	}
+
+	return strings.Join(names, "|")
+}
+
+// frame of negotiation is carried in the body of a packet:
+// magic(2) | type(1) | features(4) | count(1) | versions(2 * count)
+const (
+	negotiate_magic      uint16 = 0x4b56
+	negotiate_header_len        = 8
+)
+
+const (
+	negotiate_hello  byte = 1 // client to server, the supported versions and features
+	negotiate_accept byte = 2 // server to client, the agreed version and features
+	negotiate_reject byte = 3 // server to client, the supported versions and required features of the server
+)
+
+// Negotiation is the result agreed by both peers
+type Negotiation struct {
+	Version  uint16
+	Features Feature
+}
+
+// Negotiator exchange the supported protocol versions and features before the secure handshake,
+// the client speak first and the server choose the highest common version
+type Negotiator struct {
+	versions []uint16
+	features Feature
+	required Feature
+	isClient bool
+}
+
+func NewNegotiator(isClient bool, versions ...uint16) *Negotiator {
+	n := &Negotiator{versions: slices.Clone(versions), isClient: isClient}
+	slices.Sort(n.versions)
+	n.versions = slices.Compact(n.versions)
+	return n
+}
+
+// WithFeatures the supported features, the negotiation fails when the peer does not support all the required
+func (n *Negotiator) WithFeatures(supported, required Feature) *Negotiator {
+	n.features = supported | required
+	n.required = required
+	return n
+}
+
+func (n *Negotiator) Versions() []uint16 {
+	return n.versions
+}
+
+func encodeNegotiation(t byte, features Feature, versions []uint16) []byte {
+	body := make([]byte, negotiate_header_len+2*len(versions))
+	binary.BigEndian.PutUint16(body, negotiate_magic)
+	body[2] = t
+	binary.BigEndian.PutUint32(body[3:], uint32(features))
+	body[7] = byte(len(versions))
+	for i, v := range versions {
+		binary.BigEndian.PutUint16(body[negotiate_header_len+2*i:], v)
+	}
+
+	return body
+}
+
+func decodeNegotiation(body []byte) (t byte, features Feature, versions []uint16, err error) {
+	if len(body) < negotiate_header_len || binary.BigEndian.Uint16(body) != negotiate_magic {
+		return 0, 0, nil, Err_Negotiate
+	}
+
+	count := int(body[7])
+	if len(body) != negotiate_header_len+2*count {
+		return 0, 0, nil, Err_Negotiate
+	}
+
+	versions = make([]uint16, count)
+	for i := range versions {
+		versions[i] = binary.BigEndian.Uint16(body[negotiate_header_len+2*i:])
+	}
+
+	return body[2], Feature(binary.BigEndian.Uint32(body[3:])), versions, nil
+}
+
+// choose the highest common version and the common features
+func (n *Negotiator) choose(features Feature, versions []uint16) (Negotiation, error) {
+	if !features.Has(n.required) {
+		return Negotiation{}, fmt.Errorf("%w: %s", Err_Feature, n.required&^features)
+	}
+
+	for i := len(n.versions) - 1; i >= 0; i-- {
+		if slices.Contains(versions, n.versions[i]) {
+			return Negotiation{Version: n.versions[i], Features: n.features & features}, nil
+		}
+	}
+
+	return Negotiation{}, fmt.Errorf("%w: local %v, peer %v", Err_Version, n.versions, versions)
+}
+
+func (c *Connection) WithNegotiator(n *Negotiator) *Connection {
+	c.negotiator = n
+	return c
+}
+
+func (c *Connection) Negotiator() *Negotiator {
+	return c.negotiator
+}
+
+// Negotiated the version and features agreed with the peer, zero when there is no negotiation,
+// it is safe to call while the connection is handshaking, e.g. by the admin endpoint
+func (c *Connection) Negotiated() Negotiation {
+	if negotiated := c.negotiated.Load(); negotiated != nil {
+		return *negotiated
+	}
+
+	return Negotiation{}
+}
+
+// Version the protocol version agreed with the peer, 0 when there is no negotiation
+func (c *Connection) Version() uint16 {
+	return c.Negotiated().Version
+}
+
+// HasFeature report whether the feature is agreed by both peers
+func (c *Connection) HasFeature(feature Feature) bool {
+	return c.Negotiated().Features.Has(feature)
+}
+
+// negotiate is the first step of the handshake
+func (c *Connection) negotiate(deadline time.Time) error {
+	n := c.negotiator
+	c.negotiated.Store(nil)
+	features, required := n.features, n.required
+	if c.secure != nil {
+		features |= Feature_Encryption
+		required |= Feature_Encryption
+	}
+	local := &Negotiator{versions: n.versions, features: features, required: required, isClient: n.isClient}
+
+	if n.isClient {
+		hello := encodeNegotiation(negotiate_hello, features, n.versions)
+		if err := c.writeBody(hello, deadline); err != nil {
+			return handshakeErr(err)
+		}
+		c.transcript = append(c.transcript, hello...)
+	}
+
+	packet, err := c.Read()
+	if err != nil {
+		return handshakeErr(err)
+	}
+	c.transcript = append(c.transcript, packet.Body...)
+
+	t, peerFeatures, versions, err := decodeNegotiation(packet.Body)
+	if err != nil {
+		return err
+	}
+
+	if n.isClient {
+		var result Negotiation
+		if err := result.accept(local, t, peerFeatures, versions); err != nil {
+			return err
+		}
+		c.negotiated.Store(&result)
+		return nil
+	}
+
+	if t != negotiate_hello {
+		return Err_Negotiate
+	}
+
+	result, err := local.choose(peerFeatures, versions)
+	if err != nil {
+		c.writeBody(encodeNegotiation(negotiate_reject, required, n.versions), deadline)
+		return err
+	}
+
+	answer := encodeNegotiation(negotiate_accept, result.Features, []uint16{result.Version})
+	if err := c.writeBody(answer, deadline); err != nil {
+		return handshakeErr(err)
+	}
+	c.transcript = append(c.transcript, answer...)
+
+	c.negotiated.Store(&result)
+	return nil
+}
+
+// accept the answer of the server
+func (r *Negotiation) accept(local *Negotiator, t byte, features Feature, versions []uint16) error {
+	switch t {
+	case negotiate_reject:
+		if !local.features.Has(features) {
+			return fmt.Errorf("%w: %s", Err_Feature, features&^local.features)
+		}
+		return fmt.Errorf("%w: local %v, peer %v", Err_Version, local.versions, versions)
+	case negotiate_accept:
+		if len(versions) != 1 || !slices.Contains(local.versions, versions[0]) {
+			return Err_Negotiate
+		}
+		if !features.Has(local.required) {
+			return fmt.Errorf("%w: %s", Err_Feature, local.required&^features)
+		}
+
+		*r = Negotiation{Version: versions[0], Features: features & local.features}
+		return nil
+	}
+
+	return Err_Negotiate
+}
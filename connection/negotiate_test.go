@@ -0,0 +1,24 @@
+package connection
+
+import "testing"
+
+func TestFeatureString(t *testing.T) {
+	tests := []struct {
+		name    string
+		feature Feature
+		expect  string
+	}{
+		{"none", 0, ""},
+		{"encryption", Feature_Encryption, "encryption"},
+		{"application", 1<<8 | 1<<9, "0x300"},
+		{"mixed", Feature_Encryption | 1<<0 | 1<<31, "encryption|0x80000001"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.feature.String(); got != tt.expect {
+				t.Fatal(got)
+			}
+		})
+	}
+}
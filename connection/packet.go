@@ -3,14 +3,72 @@ package connection
 type Packet struct {
 	Header []byte
 	Body   []byte
+	raw    []byte
+	chunk  *chunk
+	header *Header
+}
+
+// newPacket the capacity of raw is capped, append to the result of Bytes must not overwrite the read buffer
+func newPacket(c *chunk, raw []byte, header *Header) *Packet {
+	headerLen := header.headerLen
+	raw = raw[:len(raw):len(raw)]
+	return &Packet{Header: raw[:headerLen:headerLen], Body: raw[headerLen:len(raw):len(raw)], raw: raw, chunk: c, header: header}
+}
+
+// Bind the header layout used by Field to decode the header fields, e.g. the packet copied or replayed
+func (p *Packet) Bind(header *Header) *Packet {
+	p.header = header
+	return p
+}
+
+// contiguous is true when Header and Body still reference the read bytes unchanged
+func (p *Packet) contiguous() bool {
+	if len(p.raw) == 0 || len(p.raw) != len(p.Header)+len(p.Body) || len(p.Header) == 0 || &p.raw[0] != &p.Header[0] {
+		return false
+	}
+
+	return len(p.Body) == 0 || &p.raw[len(p.Header)] == &p.Body[0]
 }
 
 func (p *Packet) Bytes() []byte {
-	return append(p.Header, p.Body...)
+	if p.contiguous() {
+		return p.raw
+	}
+
+	buff := make([]byte, len(p.Header)+len(p.Body))
+	copy(buff, p.Header)
+	copy(buff[len(p.Header):], p.Body)
+	return buff
+}
+
+// Release return the buffer of the packet to the pool,
+// Header, Body and the result of Bytes must not be used after Release
+func (p *Packet) Release() {
+	if p.chunk == nil {
+		return
+	}
+
+	p.chunk.release()
+	p.chunk = nil
+	p.raw = nil
+	p.Header = nil
+	p.Body = nil
 }
 
-func NewPacket(body []byte, header *Header) *Packet {
-	p := &Packet{Body: body}
-	p.Header = header.Header(len(body))
+// NewPacket the length of the header is truncated when len(body) overflows the length type, use EncodePacket to check it
+func NewPacket(body []byte, header *Header, values ...FieldValue) *Packet {
+	p := &Packet{Body: body, header: header}
+	p.Header = header.Header(len(body), values...)
 	return p
 }
+
+// EncodePacket the packet of body and the header field values, it fails when the header layout is invalid,
+// len(body) overflows the length type or a field value is invalid
+func EncodePacket(body []byte, header *Header, values ...FieldValue) (*Packet, error) {
+	h, err := header.Encode(len(body), values...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Packet{Header: h, Body: body, header: header}, nil
+}
@@ -0,0 +1,164 @@
+package connection
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+// streamConn read the packets of data again and again
+type streamConn struct {
+	data   []byte
+	offset int
+}
+
+func (c *streamConn) Read(b []byte) (int, error) {
+	n := copy(b, c.data[c.offset:])
+	c.offset = (c.offset + n) % len(c.data)
+	return n, nil
+}
+
+func (c *streamConn) Write(b []byte) (int, error)      { return len(b), nil }
+func (c *streamConn) Close() error                     { return nil }
+func (c *streamConn) LocalAddr() net.Addr              { return &net.TCPAddr{} }
+func (c *streamConn) RemoteAddr() net.Addr             { return &net.TCPAddr{} }
+func (c *streamConn) SetDeadline(time.Time) error      { return nil }
+func (c *streamConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *streamConn) SetWriteDeadline(time.Time) error { return nil }
+
+func newStreamConn(count int, body []byte) *streamConn {
+	var data []byte
+	for i := 0; i < count; i++ {
+		data = append(data, NewPacket(body, NewHeader()).Bytes()...)
+	}
+
+	return &streamConn{data: data}
+}
+
+func TestPacketBytesCapped(t *testing.T) {
+	c := NewConnection(1, newStreamConn(2, []byte("abcd"))).WithZeroCopy(64)
+	first, err := c.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := c.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b := first.Bytes(); cap(b) != len(b) {
+		t.Fatal(len(b), cap(b))
+	}
+	_ = append(first.Bytes(), "xxxxxxxx"...)
+	_ = append(first.Body, "xxxxxxxx"...)
+	if !bytes.Equal(second.Body, []byte("abcd")) {
+		t.Fatal(second.Body)
+	}
+}
+
+func TestZeroCopyRelease(t *testing.T) {
+	tests := []struct {
+		name     string
+		zeroCopy int
+	}{
+		{"copy", 0},
+		{"zero copy", 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := net.Pipe()
+			defer b.Close()
+			writer := NewConnection(1, a)
+			reader := NewConnection(2, b).WithMaxLen(64).WithZeroCopy(tt.zeroCopy)
+			go func() {
+				for i := 0; i < 100; i++ {
+					writer.Write(NewPacket(bytes.Repeat([]byte{byte(i)}, i%30), writer.Header()).Bytes())
+				}
+			}()
+
+			// the released packets are reused, the kept ones are not changed
+			var keep []*Packet
+			for i := 0; i < 100; i++ {
+				p, err := reader.Read()
+				if err != nil || !bytes.Equal(p.Body, bytes.Repeat([]byte{byte(i)}, i%30)) {
+					t.Fatal(i, err, p)
+				}
+				keep = append(keep, p)
+				if i%3 == 0 {
+					p.Release()
+				}
+			}
+			for i, p := range keep {
+				if i%3 == 0 {
+					continue
+				}
+				if !bytes.Equal(p.Body, bytes.Repeat([]byte{byte(i)}, i%30)) || len(p.Bytes()) != 4+i%30 {
+					t.Fatal(i, p.Body)
+				}
+			}
+		})
+	}
+}
+
+func TestFeed(t *testing.T) {
+	tests := []struct {
+		name  string
+		chunk int
+	}{
+		{"split", 7},
+		{"one byte", 1},
+		{"whole", 1 << 16},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewConnection(1, nil).WithMaxLen(32).WithZeroCopy(16)
+			var data []byte
+			for i := 0; i < 50; i++ {
+				data = append(data, NewPacket(bytes.Repeat([]byte{byte(i)}, i%20), c.Header()).Bytes()...)
+			}
+
+			count := 0
+			for len(data) > 0 {
+				n := min(tt.chunk, len(data))
+				packets, err := c.Feed(data[:n])
+				if err != nil {
+					t.Fatal(err)
+				}
+				data = data[n:]
+				for _, p := range packets {
+					if !bytes.Equal(p.Body, bytes.Repeat([]byte{byte(count)}, count%20)) {
+						t.Fatal(count, p.Body)
+					}
+					count++
+				}
+			}
+			if count != 50 || c.chunk != nil {
+				t.Fatal(count, c.chunk)
+			}
+		})
+	}
+}
+
+func benchmarkRead(b *testing.B, zeroCopy int) {
+	c := NewConnection(1, newStreamConn(64, bytes.Repeat([]byte{1}, 128))).WithReadBuffLen(4096).WithZeroCopy(zeroCopy)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p, err := c.Read()
+		if err != nil {
+			b.Fatal(err)
+		}
+		p.Release()
+	}
+}
+
+func BenchmarkReadCopy(b *testing.B) {
+	benchmarkRead(b, 0)
+}
+
+func BenchmarkReadZeroCopy(b *testing.B) {
+	benchmarkRead(b, 1024)
+}
@@ -0,0 +1,217 @@
+package connection
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+var Err_Unkown_Field = errors.New("unkown header field")
+var Err_Field_Overflow = errors.New("value overflows the header field")
+
+// HeaderField is a named integer field in the header, the endian of the header is used when Endian is nil
+type HeaderField struct {
+	Name   string
+	Offset int
+	Width  int // 1, 2, 4 or 8
+	Signed bool
+	Endian binary.ByteOrder
+}
+
+// FieldValue is the value of a header field to encode
+type FieldValue struct {
+	Name  string
+	Value int64
+}
+
+// Field the value of the header field name
+func Field(name string, value int64) FieldValue {
+	return FieldValue{Name: name, Value: value}
+}
+
+// Schema is the named fields of the header besides the body length
+type Schema struct {
+	fields []HeaderField
+	index  map[string]int
+}
+
+func NewSchema(fields ...HeaderField) *Schema {
+	s := &Schema{index: make(map[string]int, len(fields))}
+	for _, f := range fields {
+		s.index[f.Name] = len(s.fields)
+		s.fields = append(s.fields, f)
+	}
+
+	return s
+}
+
+func (s *Schema) Fields() []HeaderField {
+	return s.fields
+}
+
+func (s *Schema) Lookup(name string) (HeaderField, bool) {
+	i, ok := s.index[name]
+	if !ok {
+		return HeaderField{}, false
+	}
+
+	return s.fields[i], true
+}
+
+// validate reject the fields out of the header, overlapped with each other or the length field
+func (s *Schema) validate(h *Header) error {
+	if len(s.index) != len(s.fields) {
+		return fmt.Errorf("%w: duplicate field name", Err_Invalid_Header)
+	}
+
+	for i, f := range s.fields {
+		if f.Name == "" {
+			return fmt.Errorf("%w: field %d has no name", Err_Invalid_Header, i)
+		}
+		if f.Width != 1 && f.Width != 2 && f.Width != 4 && f.Width != 8 {
+			return fmt.Errorf("%w: field %s width %d is not 1, 2, 4 or 8", Err_Invalid_Header, f.Name, f.Width)
+		}
+		if f.Offset < 0 || f.Offset+f.Width > h.headerLen {
+			return fmt.Errorf("%w: field %s offset(%d) + width(%d) > header length(%d)", Err_Invalid_Header, f.Name, f.Offset, f.Width, h.headerLen)
+		}
+		if overlap(f.Offset, f.Width, h.bodyLenOffset, h.bodyLengthLen) {
+			return fmt.Errorf("%w: field %s overlaps the body length", Err_Invalid_Header, f.Name)
+		}
+		for _, o := range s.fields[:i] {
+			if overlap(f.Offset, f.Width, o.Offset, o.Width) {
+				return fmt.Errorf("%w: field %s overlaps field %s", Err_Invalid_Header, f.Name, o.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+func overlap(offset, width, other, otherWidth int) bool {
+	return offset < other+otherWidth && other < offset+width
+}
+
+func (f HeaderField) endian(h *Header) binary.ByteOrder {
+	if f.Endian != nil {
+		return f.Endian
+	}
+
+	return h.endian
+}
+
+func (f HeaderField) fits(v int64) bool {
+	bits := f.Width * 8
+	if f.Signed {
+		return bits == 64 || (v >= -1<<(bits-1) && v < 1<<(bits-1))
+	}
+
+	return v >= 0 && (bits == 64 || v < 1<<bits)
+}
+
+func (f HeaderField) put(h *Header, headers []byte, v int64) {
+	field := headers[f.Offset : f.Offset+f.Width]
+	switch f.Width {
+	case 1:
+		field[0] = byte(v)
+	case 2:
+		f.endian(h).PutUint16(field, uint16(v))
+	case 4:
+		f.endian(h).PutUint32(field, uint32(v))
+	case 8:
+		f.endian(h).PutUint64(field, uint64(v))
+	}
+}
+
+func (f HeaderField) get(h *Header, headers []byte) int64 {
+	field := headers[f.Offset : f.Offset+f.Width]
+	switch f.Width {
+	case 1:
+		if f.Signed {
+			return int64(int8(field[0]))
+		}
+		return int64(field[0])
+	case 2:
+		if f.Signed {
+			return int64(int16(f.endian(h).Uint16(field)))
+		}
+		return int64(f.endian(h).Uint16(field))
+	case 4:
+		if f.Signed {
+			return int64(int32(f.endian(h).Uint32(field)))
+		}
+		return int64(f.endian(h).Uint32(field))
+	default:
+		return int64(f.endian(h).Uint64(field))
+	}
+}
+
+// putFields write values into headers, the unkown fields and the overflowed values are skipped unless strict
+func (h *Header) putFields(headers []byte, values []FieldValue, strict bool) error {
+	for _, v := range values {
+		var f HeaderField
+		ok := h.schema != nil
+		if ok {
+			f, ok = h.schema.Lookup(v.Name)
+		}
+		if !ok || f.Offset+f.Width > len(headers) {
+			if strict {
+				return fmt.Errorf("%w: %s", Err_Unkown_Field, v.Name)
+			}
+			continue
+		}
+
+		if !f.fits(v.Value) {
+			if strict {
+				return fmt.Errorf("%w: %s=%d", Err_Field_Overflow, v.Name, v.Value)
+			}
+			continue
+		}
+
+		f.put(h, headers, v.Value)
+	}
+
+	return nil
+}
+
+// Field the value of the header field name, the unsigned 64 bits value larger than math.MaxInt64 is negative, use Uint
+func (p *Packet) Field(name string) (int64, error) {
+	if p.header == nil || p.header.schema == nil {
+		return 0, fmt.Errorf("%w: %s", Err_Unkown_Field, name)
+	}
+
+	f, ok := p.header.schema.Lookup(name)
+	if !ok || f.Offset+f.Width > len(p.Header) {
+		return 0, fmt.Errorf("%w: %s", Err_Unkown_Field, name)
+	}
+
+	return f.get(p.header, p.Header), nil
+}
+
+// Uint the value of the unsigned header field name
+func (p *Packet) Uint(name string) (uint64, error) {
+	v, err := p.Field(name)
+	return uint64(v), err
+}
+
+// Int the value of the header field name as int, it fails when the value overflows int
+func (p *Packet) Int(name string) (int, error) {
+	v, err := p.Field(name)
+	if err != nil {
+		return 0, err
+	}
+	if v > math.MaxInt || v < math.MinInt {
+		return 0, fmt.Errorf("%w: %s=%d", Err_Field_Overflow, name, v)
+	}
+
+	return int(v), nil
+}
+
+// SetField change the header field name of the packet before it is written
+func (p *Packet) SetField(name string, value int64) error {
+	if p.header == nil {
+		return fmt.Errorf("%w: %s", Err_Unkown_Field, name)
+	}
+
+	return p.header.putFields(p.Header, []FieldValue{{Name: name, Value: value}}, true)
+}
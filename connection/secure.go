@@ -0,0 +1,433 @@
+package connection
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+var Err_Handshake = NewError(Err_Protocol, errors.New("secure handshake failure"))
+var Err_Decrypt = NewError(Err_Protocol, errors.New("packet decrypt failure"))
+var Err_Replay = NewError(Err_Protocol, errors.New("packet replayed"))
+var Err_Unkown_Cipher = NewError(Err_Protocol, errors.New("unkown cipher suite"))
+
+type CipherSuite byte
+
+const (
+	Cipher_AES_128_GCM       CipherSuite = 1
+	Cipher_AES_256_GCM       CipherSuite = 2
+	Cipher_ChaCha20_Poly1305 CipherSuite = 3
+)
+
+const (
+	secure_version   byte = 1
+	secure_seq_len        = 8
+	secure_tag_len        = 16 // the tag length of all the cipher suites
+	secure_hello_len      = 2 + 32
+)
+
+// secure_finished is sealed by both peers after the key exchange, the peer derives other keys fails to open it
+var secure_finished = []byte("ko.network finished")
+
+func (c CipherSuite) keyLen() int {
+	switch c {
+	case Cipher_AES_128_GCM:
+		return 16
+	case Cipher_AES_256_GCM:
+		return 32
+	case Cipher_ChaCha20_Poly1305:
+		return chacha20poly1305.KeySize
+	default:
+		return 0
+	}
+}
+
+func (c CipherSuite) aead(key []byte) (cipher.AEAD, error) {
+	switch c {
+	case Cipher_AES_128_GCM, Cipher_AES_256_GCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case Cipher_ChaCha20_Poly1305:
+		return chacha20poly1305.New(key)
+	default:
+		return nil, Err_Unkown_Cipher
+	}
+}
+
+// secureState is one direction of the channel, the key is rotated
+// every rotateEvery packets and derived from secret and the epoch
+type secureState struct {
+	secret []byte
+	epoch  uint64
+	aead   cipher.AEAD
+	seq    uint64
+}
+
+type Secure struct {
+	suite       CipherSuite
+	isClient    bool
+	psk         []byte
+	rotateEvery uint64
+	timeout     time.Duration
+	isReady     bool
+	send        *secureState
+	recv        *secureState
+	locker      sync.Mutex
+}
+
+func NewSecure(suite CipherSuite, isClient bool) *Secure {
+	return &Secure{suite: suite, isClient: isClient, rotateEvery: 1 << 20, timeout: 10 * time.Second}
+}
+
+// WithRotateEvery rotate the session key after count packets
+func (s *Secure) WithRotateEvery(count uint64) *Secure {
+	if count > 0 {
+		s.rotateEvery = count
+	}
+	return s
+}
+
+// WithPSK mix the pre-shared key into the session keys, both peers must have the same key,
+// the handshake of a man in the middle without it fails
+func (s *Secure) WithPSK(psk []byte) *Secure {
+	s.psk = bytes.Clone(psk)
+	return s
+}
+
+func (s *Secure) WithTimeout(timeout time.Duration) *Secure {
+	s.timeout = timeout
+	return s
+}
+
+func (s *Secure) IsReady() bool {
+	return s.isReady
+}
+
+// Overhead the bytes the sequence and the tag add to every sealed body
+func (s *Secure) Overhead() int {
+	return secure_seq_len + secure_tag_len
+}
+
+func (s *Secure) hello(pub *ecdh.PublicKey) []byte {
+	return append([]byte{secure_version, byte(s.suite)}, pub.Bytes()...)
+}
+
+func (s *Secure) parseHello(body []byte) (*ecdh.PublicKey, error) {
+	if len(body) != secure_hello_len || body[0] != secure_version {
+		return nil, Err_Handshake
+	}
+	if CipherSuite(body[1]) != s.suite {
+		return nil, fmt.Errorf("%w: cipher suite mismatch", Err_Handshake)
+	}
+
+	return ecdh.X25519().NewPublicKey(body[2:])
+}
+
+func (s *Secure) derive(secret, salt []byte, info string) ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(info)), key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// setup derive the keys from the shared secret and the psk, the salt binds the public keys
+// and the transcript of the negotiation, so the peers see different frames derive different keys
+func (s *Secure) setup(priv *ecdh.PrivateKey, peer *ecdh.PublicKey, transcript []byte) error {
+	shared, err := priv.ECDH(peer)
+	if err != nil {
+		return err
+	}
+
+	var salt []byte
+	if s.isClient {
+		salt = append(priv.PublicKey().Bytes(), peer.Bytes()...)
+	} else {
+		salt = append(peer.Bytes(), priv.PublicKey().Bytes()...)
+	}
+	sum := sha256.Sum256(transcript)
+	salt = append(salt, sum[:]...)
+	shared = append(shared, s.psk...)
+
+	c2s, err := s.derive(shared, salt, "ko.network c2s")
+	if err != nil {
+		return err
+	}
+	s2c, err := s.derive(shared, salt, "ko.network s2c")
+	if err != nil {
+		return err
+	}
+
+	if s.isClient {
+		s.send, s.recv = &secureState{secret: c2s}, &secureState{secret: s2c}
+	} else {
+		s.send, s.recv = &secureState{secret: s2c}, &secureState{secret: c2s}
+	}
+
+	s.isReady = true
+	return nil
+}
+
+func (s *Secure) aeadOf(state *secureState, seq uint64) (cipher.AEAD, error) {
+	epoch := seq / s.rotateEvery
+	if state.aead != nil && state.epoch == epoch {
+		return state.aead, nil
+	}
+
+	var info [8]byte
+	binary.BigEndian.PutUint64(info[:], epoch)
+	key, err := s.derive(state.secret, nil, "ko.network epoch"+string(info[:]))
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := s.suite.aead(key[:s.suite.keyLen()])
+	if err != nil {
+		return nil, err
+	}
+
+	state.aead = aead
+	state.epoch = epoch
+	return aead, nil
+}
+
+func (s *Secure) nonce(aead cipher.AEAD, seq uint64) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-secure_seq_len:], seq)
+	return nonce
+}
+
+// additional the header authenticated with the body, the body length and the checksum
+// are zero as they change with the sealed body
+func (h *Header) additional(headers []byte) []byte {
+	ad := make([]byte, h.headerLen)
+	copy(ad, headers)
+	clear(ad[h.bodyLenOffset : h.bodyLenOffset+h.bodyLengthLen])
+	if cs := h.checksum; cs != nil {
+		clear(ad[cs.offset : cs.offset+cs.t.Width()])
+	}
+
+	return ad
+}
+
+func (s *Secure) seal(body, additional []byte) ([]byte, error) {
+	s.send.seq++
+	seq := s.send.seq
+	aead, err := s.aeadOf(s.send, seq)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, secure_seq_len, secure_seq_len+len(body)+aead.Overhead())
+	binary.BigEndian.PutUint64(out, seq)
+	return aead.Seal(out, s.nonce(aead, seq), body, additional), nil
+}
+
+func (s *Secure) open(body, additional []byte) ([]byte, error) {
+	if len(body) < secure_seq_len {
+		return nil, Err_Decrypt
+	}
+
+	seq := binary.BigEndian.Uint64(body)
+	if seq <= s.recv.seq {
+		return nil, Err_Replay
+	}
+
+	aead, err := s.aeadOf(s.recv, seq)
+	if err != nil {
+		return nil, err
+	}
+
+	plain, err := aead.Open(nil, s.nonce(aead, seq), body[secure_seq_len:], additional)
+	if err != nil {
+		return nil, Err_Decrypt
+	}
+
+	s.recv.seq = seq
+	return plain, nil
+}
+
+// Handshake negotiate the protocol version when the negotiator is set,
+// then exchange X25519 public keys with the peer and confirm the keys, the client speak first.
+// The exchange is not authenticated without the psk, it only protects against passive eavesdropping then
+func (c *Connection) Handshake() error {
+	s := c.secure
+	if s == nil && c.negotiator == nil {
+		return nil
+	}
+
+	var deadline time.Time
+	if c.handshakeTimeout > 0 {
+		deadline = time.Now().Add(c.handshakeTimeout)
+	} else if s != nil && s.timeout > 0 {
+		deadline = time.Now().Add(s.timeout)
+	}
+	c.readDeadline = deadline
+	defer func() {
+		c.readDeadline = time.Time{}
+	}()
+
+	c.transcript = nil
+	if c.negotiator != nil {
+		if err := c.negotiate(deadline); err != nil {
+			return err
+		}
+	}
+	if s == nil {
+		return nil
+	}
+
+	s.isReady = false
+	s.send, s.recv = nil, nil
+	if s.suite.keyLen() == 0 {
+		return Err_Unkown_Cipher
+	}
+
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	if s.isClient {
+		if err := c.writeBody(s.hello(priv.PublicKey()), deadline); err != nil {
+			return handshakeErr(err)
+		}
+	}
+
+	packet, err := c.Read()
+	if err != nil {
+		return handshakeErr(err)
+	}
+
+	peer, err := s.parseHello(packet.Body)
+	if err != nil {
+		return err
+	}
+
+	if !s.isClient {
+		if err := c.writeBody(s.hello(priv.PublicKey()), deadline); err != nil {
+			return handshakeErr(err)
+		}
+	}
+
+	if err := s.setup(priv, peer, c.transcript); err != nil {
+		return err
+	}
+
+	return c.confirm(deadline)
+}
+
+// confirm exchange the sealed finished frames, it fails when the peers derive different keys
+func (c *Connection) confirm(deadline time.Time) error {
+	p, err := EncodePacket(secure_finished, c.header)
+	if err != nil {
+		return err
+	}
+
+	if c.secure.isClient {
+		if err := c.send(p.Bytes(), deadline); err != nil {
+			return handshakeErr(err)
+		}
+	}
+
+	packet, err := c.Read()
+	if err != nil {
+		if errors.Is(err, Err_Decrypt) {
+			return fmt.Errorf("%w: %w", Err_Handshake, err)
+		}
+		return handshakeErr(err)
+	}
+	if !bytes.Equal(packet.Body, secure_finished) {
+		return Err_Handshake
+	}
+
+	if !c.secure.isClient {
+		if err := c.send(p.Bytes(), deadline); err != nil {
+			return handshakeErr(err)
+		}
+	}
+
+	return nil
+}
+
+func handshakeErr(err error) error {
+	if err == Err_Read_Timeout || err == Err_Write_Timeout {
+		return Err_Handshake_Timeout
+	}
+
+	return err
+}
+
+func (c *Connection) WithSecure(secure *Secure) *Connection {
+	c.secure = secure
+	return c
+}
+
+func (c *Connection) Secure() *Secure {
+	return c.secure
+}
+
+func (c *Connection) encrypt(data []byte) ([]byte, error) {
+	var out []byte
+	err := c.frames(data, func(header, body []byte) error {
+		if err := c.checkSealedLen(len(body) + c.secure.Overhead()); err != nil {
+			return err
+		}
+
+		sealed, err := c.secure.seal(body, c.header.additional(header))
+		if err != nil {
+			return err
+		}
+
+		buff := make([]byte, len(header))
+		copy(buff, header)
+		if err := c.header.PutBodyLen(buff, len(sealed)); err != nil {
+			return err
+		}
+		out = append(out, buff...)
+		out = append(out, sealed...)
+		return nil
+	})
+
+	return out, err
+}
+
+// checkSealedLen reject the sealed body the length type can not hold or longer than maxLen,
+// the peer with the same max length would reject it
+func (c *Connection) checkSealedLen(length int) error {
+	if max := c.header.bodyLenType.Max(); length > max {
+		return fmt.Errorf("%w: sealed body %d > %d", Err_Body_Len_Overflow, length, max)
+	}
+	if length > c.maxLen-c.header.headerLen {
+		return fmt.Errorf("%w: sealed body %d > max length(%d) - header length(%d)", Err_Packet_Out_Range, length, c.maxLen, c.header.headerLen)
+	}
+
+	return nil
+}
+
+func (c *Connection) decrypt(p *Packet) error {
+	body, err := c.secure.open(p.Body, c.header.additional(p.Header))
+	if err != nil {
+		return err
+	}
+
+	p.Body = body
+	c.header.putBodyLen(p.Header, len(body))
+	return nil
+}
@@ -0,0 +1,199 @@
+package connection
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+// securePair the handshaked client and server connections over a pipe
+func securePair(t *testing.T, suite CipherSuite, setup func(c *Connection)) (*Connection, *Connection) {
+	t.Helper()
+	a, b := net.Pipe()
+	client := NewConnection(1, a).WithSecure(NewSecure(suite, true).WithRotateEvery(2))
+	server := NewConnection(2, b).WithSecure(NewSecure(suite, false).WithRotateEvery(2))
+	if setup != nil {
+		setup(client)
+		setup(server)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- server.Handshake() }()
+	if err := client.Handshake(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	return client, server
+}
+
+func TestSecureReadWrite(t *testing.T) {
+	for _, suite := range []CipherSuite{Cipher_AES_128_GCM, Cipher_AES_256_GCM, Cipher_ChaCha20_Poly1305} {
+		client, server := securePair(t, suite, nil)
+		go func() {
+			for i := 0; i < 5; i++ {
+				p := NewPacket([]byte("hello"), client.Header())
+				client.Write(append(p.Bytes(), p.Bytes()...))
+			}
+		}()
+
+		for i := 0; i < 10; i++ {
+			p, err := server.Read()
+			if err != nil {
+				t.Fatal(suite, i, err)
+			}
+			if string(p.Body) != "hello" || p.Header[3] != 5 {
+				t.Fatal(suite, i, p.Header, p.Body)
+			}
+		}
+	}
+}
+
+func TestSecureSealedLen(t *testing.T) {
+	client, _ := securePair(t, Cipher_AES_128_GCM, func(c *Connection) {
+		c.WithBodyLenType(Len_Type_Int8)
+	})
+	body := bytes.Repeat([]byte{1}, 127-client.Secure().Overhead()+1)
+	if err := client.Write(NewPacket(body, client.Header()).Bytes()); !errors.Is(err, Err_Body_Len_Overflow) {
+		t.Fatal(err)
+	}
+
+	client, _ = securePair(t, Cipher_AES_128_GCM, func(c *Connection) {
+		c.WithMaxLen(64)
+	})
+	body = bytes.Repeat([]byte{1}, 64-4-client.Secure().Overhead()+1)
+	if err := client.Write(NewPacket(body, client.Header()).Bytes()); !errors.Is(err, Err_Packet_Out_Range) {
+		t.Fatal(err)
+	}
+}
+
+func TestSecureUnkownCipher(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+	c := NewConnection(1, a).WithSecure(NewSecure(CipherSuite(9), true))
+	if err := c.Handshake(); !errors.Is(err, Err_Unkown_Cipher) || !errors.Is(err, Err_Protocol) {
+		t.Fatal(err)
+	}
+}
+
+func TestSecureAdditional(t *testing.T) {
+	client, server := securePair(t, Cipher_AES_128_GCM, func(c *Connection) {
+		c.WithHeaderLen(9).WithKind(4).WithChecksum(Checksum_CRC32, 5)
+	})
+
+	seal := func() []byte {
+		p, _ := EncodeFrame(Kind_Push, []byte("hello"), client.Header())
+		data, err := client.encrypt(p.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	open := func(data []byte) error {
+		return server.decrypt(&Packet{Header: data[:9], Body: data[9:], header: server.Header()})
+	}
+
+	tampered := seal()
+	tampered[4] = byte(Kind_Data)
+	if err := open(tampered); !errors.Is(err, Err_Decrypt) {
+		t.Fatal(err)
+	}
+
+	// the checksum is written after sealing, it is not authenticated
+	data := seal()
+	data[5] = 0xff
+	if err := open(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// handshake run the handshake of both sides, the side failed closes its connection
+func handshake(client, server *Connection) (error, error) {
+	done := make(chan error, 1)
+	go func() {
+		err := server.Handshake()
+		if err != nil {
+			server.Close()
+		}
+		done <- err
+	}()
+	err := client.Handshake()
+	if err != nil {
+		client.Close()
+	}
+	return err, <-done
+}
+
+func TestSecurePSK(t *testing.T) {
+	tests := []struct {
+		name   string
+		client []byte
+		server []byte
+		ok     bool
+	}{
+		{"same", []byte("key"), []byte("key"), true},
+		{"different", []byte("key"), []byte("other"), false},
+		{"missing", nil, []byte("key"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := net.Pipe()
+			client := NewConnection(1, a).WithSecure(NewSecure(Cipher_AES_128_GCM, true).WithPSK(tt.client))
+			server := NewConnection(2, b).WithSecure(NewSecure(Cipher_AES_128_GCM, false).WithPSK(tt.server))
+			defer client.Close()
+			defer server.Close()
+
+			cerr, serr := handshake(client, server)
+			if tt.ok && (cerr != nil || serr != nil) {
+				t.Fatal(cerr, serr)
+			}
+			if !tt.ok && !errors.Is(serr, Err_Handshake) {
+				t.Fatal(cerr, serr)
+			}
+		})
+	}
+}
+
+// TestSecureTranscript a man in the middle strips a feature from the negotiation hello of the client
+func TestSecureTranscript(t *testing.T) {
+	a, m := net.Pipe()
+	n, b := net.Pipe()
+	client := NewConnection(1, a).WithSecure(NewSecure(Cipher_AES_128_GCM, true)).
+		WithNegotiator(NewNegotiator(true, 1).WithFeatures(1<<8, 0))
+	server := NewConnection(2, b).WithSecure(NewSecure(Cipher_AES_128_GCM, false)).
+		WithNegotiator(NewNegotiator(false, 1).WithFeatures(1<<8, 0))
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		defer n.Close()
+		head := make([]byte, 4)
+		io.ReadFull(m, head)
+		body := make([]byte, binary.BigEndian.Uint32(head))
+		io.ReadFull(m, body)
+		features := binary.BigEndian.Uint32(body[3:])
+		binary.BigEndian.PutUint32(body[3:], features&^(1<<8))
+		n.Write(append(head, body...))
+		go func() {
+			io.Copy(m, n)
+			m.Close()
+		}()
+		io.Copy(n, m)
+	}()
+
+	cerr, serr := handshake(client, server)
+	if !errors.Is(serr, Err_Handshake) {
+		t.Fatal(cerr, serr)
+	}
+}
@@ -0,0 +1,39 @@
+package connection
+
+import "sync/atomic"
+
+// Stats is the snapshot of the counters of a connection
+type Stats struct {
+	BytesRead        uint64 `json:"bytes_read"`
+	BytesWritten     uint64 `json:"bytes_written"`
+	PacketsRead      uint64 `json:"packets_read"`
+	Writes           uint64 `json:"writes"` // successful write calls
+	ReadTimeouts     uint64 `json:"read_timeouts"`
+	WriteTimeouts    uint64 `json:"write_timeouts"`
+	IOErrors         uint64 `json:"io_errors"`         // read and write errors except timeouts
+	ChecksumFailures uint64 `json:"checksum_failures"` // packets whose checksum mismatches
+}
+
+type counters struct {
+	bytesRead        atomic.Uint64
+	bytesWritten     atomic.Uint64
+	packetsRead      atomic.Uint64
+	writes           atomic.Uint64
+	readTimeouts     atomic.Uint64
+	writeTimeouts    atomic.Uint64
+	ioErrors         atomic.Uint64
+	checksumFailures atomic.Uint64
+}
+
+func (c *Connection) Stats() Stats {
+	return Stats{
+		BytesRead:        c.counters.bytesRead.Load(),
+		BytesWritten:     c.counters.bytesWritten.Load(),
+		PacketsRead:      c.counters.packetsRead.Load(),
+		Writes:           c.counters.writes.Load(),
+		ReadTimeouts:     c.counters.readTimeouts.Load(),
+		WriteTimeouts:    c.counters.writeTimeouts.Load(),
+		IOErrors:         c.counters.ioErrors.Load(),
+		ChecksumFailures: c.counters.checksumFailures.Load(),
+	}
+}
@@ -0,0 +1,14 @@
+package connection
+
+// ITap observe the packets of the connection, the packets read are decrypted and the packets written
+// are not encrypted yet, the packets must not be retained or modified after the call
+type ITap interface {
+	OnRead(c *Connection, p *Packet)
+	OnWrite(c *Connection, p *Packet)
+}
+
+// WithTap observe the packets read and written by the connection
+func (c *Connection) WithTap(tap ITap) *Connection {
+	c.tap = tap
+	return c
+}
@@ -3,6 +3,11 @@ module github.com/kovey/network-go/v2
 go 1.22
 
 require (
+	github.com/cespare/xxhash/v2 v2.3.0
 	github.com/kovey/debug-go v0.1.2
 	github.com/kovey/pool v0.0.9
+	golang.org/x/crypto v0.32.0
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+require golang.org/x/sys v0.29.0 // indirect
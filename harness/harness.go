@@ -0,0 +1,276 @@
+package harness
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kovey/network-go/v2/client"
+	"github.com/kovey/network-go/v2/connection"
+	"github.com/kovey/network-go/v2/server"
+)
+
+const harness_host = "harness"
+
+var nextPort atomic.Int32
+
+var errFull = errors.New("harness: too many packets are not expected")
+
+// Harness boot a server with the handler on an in-memory transport and connect simulated clients to it,
+// everything is shut down when the test finished
+type Harness struct {
+	tb      testing.TB
+	server  *server.Server
+	service *server.TcpService
+	port    int
+	timeout time.Duration
+	clients []*Client
+	serving chan struct{}
+	started bool
+	closed  bool
+	setup   func(*client.Tcp)
+}
+
+func New(tb testing.TB, handler server.IHandler) *Harness {
+	port := int(nextPort.Add(1))
+	service := server.NewMemoryService(1024)
+	return &Harness{tb: tb, service: service, server: server.NewServer(harness_host, port).WithService(service).WithHandler(handler), port: port, timeout: time.Second, serving: make(chan struct{})}
+}
+
+// Server configure the server before Start
+func (h *Harness) Server() *server.Server {
+	return h.server
+}
+
+// Service configure the service before Start
+func (h *Harness) Service() *server.TcpService {
+	return h.service
+}
+
+// WithTimeout the time Expect waits for a packet, the default is one second
+func (h *Harness) WithTimeout(timeout time.Duration) *Harness {
+	h.timeout = timeout
+	return h
+}
+
+// WithClientSetup configure every client before it dials, e.g. the header layout the same as the service
+func (h *Harness) WithClientSetup(setup func(*client.Tcp)) *Harness {
+	h.setup = setup
+	return h
+}
+
+// Start serve until Close
+func (h *Harness) Start() *Harness {
+	h.tb.Helper()
+	ready := make(chan struct{})
+	onSuccess := h.server.OnSuccess
+	h.server.OnSuccess = func(s *server.Server) {
+		if onSuccess != nil {
+			onSuccess(s)
+		}
+		close(ready)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		defer close(h.serving)
+		if err := h.server.ServeContext(context.Background()); err != nil {
+			errs <- err
+		}
+	}()
+
+	select {
+	case <-ready:
+	case err := <-errs:
+		h.tb.Fatalf("harness: serve failure, error: %s", err)
+	case <-time.After(h.timeout):
+		h.tb.Fatalf("harness: serve timeout")
+	}
+
+	h.started = true
+	h.tb.Cleanup(h.Close)
+	return h
+}
+
+// Connect n clients to the server
+func (h *Harness) Connect(n int) []*Client {
+	h.tb.Helper()
+	clients := make([]*Client, n)
+	for i := range clients {
+		clients[i] = h.Client()
+	}
+
+	return clients
+}
+
+// Client connect a client to the server
+func (h *Harness) Client() *Client {
+	h.tb.Helper()
+	c := newClient(h)
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+	if err := c.cli.DialContext(ctx, harness_host, h.port); err != nil {
+		h.tb.Fatalf("harness: dial failure, error: %s", err)
+	}
+
+	go func() {
+		defer close(c.listening)
+		c.cli.Listen()
+	}()
+	h.clients = append(h.clients, c)
+	return c
+}
+
+// WaitConns wait until the server has n connections
+func (h *Harness) WaitConns(n int) {
+	h.tb.Helper()
+	deadline := time.Now().Add(h.timeout)
+	for len(h.server.Conns()) != n {
+		if time.Now().After(deadline) {
+			h.tb.Fatalf("harness: wait for %d connections timeout, got %d", n, len(h.server.Conns()))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Close the clients and shutdown the server, it returns after all goroutines of them exited
+func (h *Harness) Close() {
+	if h.closed || !h.started {
+		return
+	}
+
+	h.closed = true
+	for _, c := range h.clients {
+		c.Close()
+	}
+	h.server.Shutdown()
+	<-h.serving
+}
+
+// Client is a simulated client, the packets received are kept until Expect
+type Client struct {
+	h         *Harness
+	tcp       *client.Tcp
+	cli       *client.Client
+	packets   chan *connection.Packet
+	reason    atomic.Value
+	listening chan struct{}
+}
+
+func newClient(h *Harness) *Client {
+	c := &Client{h: h, tcp: client.NewMemory(), packets: make(chan *connection.Packet, 1024), listening: make(chan struct{})}
+	if h.setup != nil {
+		h.setup(c.tcp)
+	}
+	c.cli = client.NewClient().WithService(c.tcp).WithHandler(&receiver{c: c})
+	return c
+}
+
+func (c *Client) Client() *client.Client {
+	return c.cli
+}
+
+func (c *Client) Conn() *connection.Connection {
+	return c.tcp.Connection()
+}
+
+// Send the body as a packet with the header field values
+func (c *Client) Send(body []byte, values ...connection.FieldValue) {
+	c.h.tb.Helper()
+	packet, err := connection.EncodePacket(body, c.Conn().Header(), values...)
+	if err != nil {
+		c.h.tb.Fatalf("harness: encode failure, error: %s", err)
+	}
+	if err := c.cli.Send(packet.Bytes()); err != nil {
+		c.h.tb.Fatalf("harness: send failure, error: %s", err)
+	}
+}
+
+// Expect the next packet received in the timeout of the harness
+func (c *Client) Expect() *connection.Packet {
+	c.h.tb.Helper()
+	select {
+	case packet := <-c.packets:
+		return packet
+	case <-time.After(c.h.timeout):
+		c.h.tb.Fatalf("harness: expect packet timeout after %s", c.h.timeout)
+		return nil
+	}
+}
+
+// ExpectBody the body of the next packet is body
+func (c *Client) ExpectBody(body []byte) *connection.Packet {
+	c.h.tb.Helper()
+	packet := c.Expect()
+	if !bytes.Equal(packet.Body, body) {
+		c.h.tb.Fatalf("harness: expect body %q, got %q", body, packet.Body)
+	}
+
+	return packet
+}
+
+// ExpectNone no packet is received in wait
+func (c *Client) ExpectNone(wait time.Duration) {
+	c.h.tb.Helper()
+	select {
+	case packet := <-c.packets:
+		c.h.tb.Fatalf("harness: expect no packet, got %q", packet.Body)
+	case <-time.After(wait):
+	}
+}
+
+// ExpectClosed the connection is closed by the server in the timeout of the harness, it returns the close reason
+func (c *Client) ExpectClosed() error {
+	c.h.tb.Helper()
+	select {
+	case <-c.listening:
+		box, _ := c.reason.Load().(reasonBox)
+		return box.err
+	case <-time.After(c.h.timeout):
+		c.h.tb.Fatalf("harness: expect closed timeout after %s", c.h.timeout)
+		return nil
+	}
+}
+
+// Close the client and wait for its goroutines exited
+func (c *Client) Close() {
+	c.cli.Shutdown()
+	<-c.listening
+}
+
+type reasonBox struct {
+	err error
+}
+
+// receiver is the client.IHandler of the simulated client
+type receiver struct {
+	c *Client
+}
+
+func (r *receiver) Receive(packet *connection.Packet, _ *client.Client) error {
+	p := (&connection.Packet{Header: append([]byte(nil), packet.Header...), Body: append([]byte(nil), packet.Body...)}).Bind(r.c.Conn().Header())
+	select {
+	case r.c.packets <- p:
+		return nil
+	default:
+		return errFull
+	}
+}
+
+func (r *receiver) Idle(*client.Client) error {
+	return nil
+}
+
+func (r *receiver) Try(*client.Client) bool {
+	return false
+}
+
+func (r *receiver) Shutdown() {
+}
+
+func (r *receiver) Closed(_ *client.Client, reason error) {
+	r.c.reason.Store(reasonBox{err: reason})
+}
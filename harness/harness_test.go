@@ -0,0 +1,266 @@
+package harness
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/kovey/network-go/v2/client"
+	"github.com/kovey/network-go/v2/connection"
+	"github.com/kovey/network-go/v2/server"
+)
+
+// echoHandler echo the body, broadcast by "all" and kick by "kick"
+type echoHandler struct {
+	s *server.Server
+}
+
+func (e *echoHandler) Connect(conn *connection.Connection) error {
+	e.s.Join("all", conn)
+	return nil
+}
+
+func (e *echoHandler) Receive(ctx *server.Context) error {
+	switch string(ctx.Data.Body) {
+	case "kick":
+		go e.s.Kick(ctx.Conn)
+		return nil
+	case "all":
+		e.s.Broadcast("all", connection.NewPacket([]byte("hi all"), ctx.Conn.Header()).Bytes())
+		return nil
+	}
+
+	return ctx.Conn.Write(connection.NewPacket(append([]byte("echo:"), ctx.Data.Body...), ctx.Conn.Header()).Bytes())
+}
+
+func (e *echoHandler) Close(*connection.Connection) error { return nil }
+
+func start(t *testing.T) (*Harness, []*Client) {
+	e := &echoHandler{}
+	h := New(t, e).WithTimeout(time.Second)
+	e.s = h.Server()
+	h.Start()
+	clients := h.Connect(3)
+	h.WaitConns(3)
+	return h, clients
+}
+
+func TestHarnessEcho(t *testing.T) {
+	_, clients := start(t)
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"short", "x"},
+		{"long", string(make([]byte, 1000))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clients[0].Send([]byte(tt.body))
+			clients[0].ExpectBody([]byte("echo:" + tt.body))
+			clients[1].ExpectNone(20 * time.Millisecond)
+		})
+	}
+}
+
+func TestHarnessBroadcast(t *testing.T) {
+	_, clients := start(t)
+	clients[1].Send([]byte("all"))
+	for _, c := range clients {
+		c.ExpectBody([]byte("hi all"))
+	}
+}
+
+func TestHarnessKick(t *testing.T) {
+	h, clients := start(t)
+	clients[2].Send([]byte("kick"))
+	if reason := clients[2].ExpectClosed(); !errors.Is(reason, connection.Err_EOF) {
+		t.Fatal(reason)
+	}
+	h.WaitConns(2)
+}
+
+func TestHarnessCleanup(t *testing.T) {
+	before := runtime.NumGoroutine()
+	t.Run("inner", func(t *testing.T) {
+		start(t)
+	})
+
+	for i := 0; i < 100 && runtime.NumGoroutine() > before+2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before+2 {
+		buff := make([]byte, 1<<16)
+		n := runtime.Stack(buff, true)
+		t.Fatal(before, after, string(buff[:n]))
+	}
+}
+
+// fieldHandler answer with the cmd field plus one, the seq and ver fields are kept
+type fieldHandler struct{}
+
+func (fieldHandler) Connect(*connection.Connection) error { return nil }
+
+func (fieldHandler) Receive(ctx *server.Context) error {
+	cmd, err := ctx.Data.Field("cmd")
+	if err != nil {
+		return err
+	}
+	seq, _ := ctx.Data.Field("seq")
+	ver, _ := ctx.Data.Field("ver")
+	return ctx.Conn.Write(connection.NewPacket(ctx.Data.Body, ctx.Conn.Header(), connection.Field("cmd", cmd+1), connection.Field("seq", seq), connection.Field("ver", ver)).Bytes())
+}
+
+func (fieldHandler) Close(*connection.Connection) error { return nil }
+
+func schema() *connection.Schema {
+	return connection.NewSchema(
+		connection.HeaderField{Name: "ver", Offset: 0, Width: 1},
+		connection.HeaderField{Name: "cmd", Offset: 1, Width: 2},
+		connection.HeaderField{Name: "seq", Offset: 3, Width: 4, Signed: true, Endian: binary.LittleEndian},
+	)
+}
+
+func TestHarnessSchema(t *testing.T) {
+	h := New(t, fieldHandler{})
+	h.Service().WithHeaderLen(11).WithBodyLenOffset(7).WithSchema(schema())
+	h.WithClientSetup(func(tcp *client.Tcp) { tcp.WithHeaderLen(11).WithBodyLenOffset(7).WithSchema(schema()) })
+	h.Start()
+	c := h.Client()
+	c.Send([]byte("x"), connection.Field("ver", 2), connection.Field("cmd", 1000), connection.Field("seq", -5))
+	p := c.Expect()
+	cmd, _ := p.Field("cmd")
+	seq, _ := p.Field("seq")
+	ver, _ := p.Int("ver")
+	if cmd != 1001 || seq != -5 || ver != 2 || string(p.Body) != "x" {
+		t.Fatal(cmd, seq, ver, p.Header)
+	}
+
+	tests := []struct {
+		name  string
+		field string
+		value int64
+		err   error
+	}{
+		{"unkown", "nope", 1, connection.Err_Unkown_Field},
+		{"overflow", "cmd", 70000, connection.Err_Field_Overflow},
+		{"signed", "seq", -7, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := p.SetField(tt.field, tt.value); !errors.Is(err, tt.err) {
+				t.Fatal(err)
+			}
+			if tt.err != nil {
+				return
+			}
+			if v, _ := p.Field(tt.field); v != tt.value {
+				t.Fatal(v)
+			}
+		})
+	}
+}
+
+func TestHarnessChecksumSecure(t *testing.T) {
+	e := &echoHandler{}
+	h := New(t, e)
+	e.s = h.Server()
+	h.Service().WithHeaderLen(12).WithChecksum(connection.Checksum_CRC32C, 4).WithSecure(connection.Cipher_AES_128_GCM, 3)
+	h.WithClientSetup(func(tcp *client.Tcp) {
+		tcp.WithHeaderLen(12).WithChecksum(connection.Checksum_CRC32C, 4).WithSecure(connection.Cipher_AES_128_GCM, 3)
+	})
+	h.Start()
+	c := h.Client()
+	// the keys are rotated every 3 packets
+	for i := 0; i < 10; i++ {
+		c.Send([]byte("abc"))
+		c.ExpectBody([]byte("echo:abc"))
+	}
+}
+
+// tagHandler echo the body with the tag
+type tagHandler struct {
+	tag string
+}
+
+func (e tagHandler) Connect(*connection.Connection) error { return nil }
+
+func (e tagHandler) Receive(ctx *server.Context) error {
+	return ctx.Conn.Write(connection.NewPacket(append([]byte(e.tag), ctx.Data.Body...), ctx.Conn.Header()).Bytes())
+}
+
+func (e tagHandler) Close(*connection.Connection) error { return nil }
+
+func TestHarnessVersion(t *testing.T) {
+	const feature_a, feature_b connection.Feature = 1 << 8, 1 << 9
+	router := server.NewVersionRouter().Handle(2, tagHandler{"v2:"}).WithDefault(tagHandler{"old:"})
+	h := New(t, router)
+	h.Service().WithVersions(3, 1, 2).WithFeatures(feature_a|feature_b, 0).WithSecure(connection.Cipher_AES_256_GCM, 0)
+	var versions []uint16
+	var supported, required connection.Feature
+	h.WithClientSetup(func(tcp *client.Tcp) {
+		tcp.WithVersions(versions...).WithFeatures(supported, required).WithSecure(connection.Cipher_AES_256_GCM, 0)
+	})
+	h.Start()
+
+	tests := []struct {
+		name      string
+		versions  []uint16
+		supported connection.Feature
+		required  connection.Feature
+		version   uint16
+		features  string
+		body      string
+	}{
+		{"highest common", []uint16{1, 2, 5}, feature_a, 0, 2, "encryption|0x100", "v2:a"},
+		{"default handler", []uint16{1}, 0, 0, 1, "encryption", "old:a"},
+		{"required", []uint16{1}, 0, feature_b, 1, "encryption|0x200", "old:a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			versions, supported, required = tt.versions, tt.supported, tt.required
+			c := h.Client()
+			negotiated := c.Conn().Negotiated()
+			if negotiated.Version != tt.version || negotiated.Features.String() != tt.features {
+				t.Fatal(negotiated)
+			}
+			c.Send([]byte("a"))
+			c.ExpectBody([]byte(tt.body))
+		})
+	}
+}
+
+func TestHarnessVersionRejected(t *testing.T) {
+	h := New(t, tagHandler{}).WithTimeout(time.Second)
+	h.Service().WithVersions(1, 2).WithSecure(connection.Cipher_AES_256_GCM, 0)
+	h.Start()
+
+	tests := []struct {
+		name  string
+		setup func(tcp *client.Tcp)
+		err   error
+	}{
+		{"no common version", func(tcp *client.Tcp) { tcp.WithVersions(7).WithSecure(connection.Cipher_AES_256_GCM, 0) }, connection.Err_Version},
+		{"no encryption", func(tcp *client.Tcp) { tcp.WithVersions(1) }, connection.Err_Feature},
+		{"unsupported feature", func(tcp *client.Tcp) {
+			tcp.WithVersions(1).WithFeatures(0, 1<<8).WithSecure(connection.Cipher_AES_256_GCM, 0)
+		}, connection.Err_Feature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tcp := client.NewMemory()
+			tt.setup(tcp)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			if err := tcp.DialContext(ctx, harness_host, h.port); !errors.Is(err, tt.err) {
+				t.Fatal(err)
+			}
+		})
+	}
+}
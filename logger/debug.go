@@ -0,0 +1,56 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kovey/debug-go/debug"
+)
+
+// Debug adapt github.com/kovey/debug-go to ILogger, the fields are appended to the message as key=value,
+// the debug level is written as info
+type Debug struct {
+	fields []Field
+}
+
+func NewDebug() *Debug {
+	return &Debug{}
+}
+
+func (d *Debug) format(msg string, fields []Field) string {
+	fields = expand(append(d.fields[:len(d.fields):len(d.fields)], fields...))
+	if len(fields) == 0 {
+		return msg
+	}
+
+	var builder strings.Builder
+	builder.WriteString(msg)
+	for _, field := range fields {
+		builder.WriteString(", ")
+		builder.WriteString(field.Key)
+		builder.WriteString("=")
+		builder.WriteString(fmt.Sprint(field.Value))
+	}
+
+	return builder.String()
+}
+
+func (d *Debug) Debug(msg string, fields ...Field) {
+	debug.Info("%s", d.format(msg, fields))
+}
+
+func (d *Debug) Info(msg string, fields ...Field) {
+	debug.Info("%s", d.format(msg, fields))
+}
+
+func (d *Debug) Warn(msg string, fields ...Field) {
+	debug.Warn("%s", d.format(msg, fields))
+}
+
+func (d *Debug) Erro(msg string, fields ...Field) {
+	debug.Erro("%s", d.format(msg, fields))
+}
+
+func (d *Debug) With(fields ...Field) ILogger {
+	return &Debug{fields: append(d.fields[:len(d.fields):len(d.fields)], fields...)}
+}
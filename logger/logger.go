@@ -0,0 +1,120 @@
+package logger
+
+import (
+	"errors"
+	"net"
+	"sync/atomic"
+)
+
+type Level int
+
+const (
+	Level_Debug Level = -4
+	Level_Info  Level = 0
+	Level_Warn  Level = 4
+	Level_Erro  Level = 8
+)
+
+func (l Level) String() string {
+	switch {
+	case l <= Level_Debug:
+		return "debug"
+	case l <= Level_Info:
+		return "info"
+	case l <= Level_Warn:
+		return "warn"
+	default:
+		return "erro"
+	}
+}
+
+// Field is a structured key value pair of a log record
+type Field struct {
+	Key   string
+	Value any
+}
+
+func F(key string, value any) Field {
+	return Field{Key: key, Value: value}
+}
+
+func FD(fd uint64) Field {
+	return Field{Key: "fd", Value: fd}
+}
+
+func Remote(addr net.Addr) Field {
+	if addr == nil {
+		return Field{Key: "remote", Value: ""}
+	}
+
+	return Field{Key: "remote", Value: addr.String()}
+}
+
+func Trace(id string) Field {
+	return Field{Key: "trace_id", Value: id}
+}
+
+// Err the error field, the adapters add the kind field when the error is classified by connection.ErrorKind
+func Err(err error) Field {
+	return Field{Key: "error", Value: err}
+}
+
+// kinder is implemented by connection.ErrorKind
+type kinder interface {
+	error
+	KindName() string
+}
+
+// expand append the kind of the error fields
+func expand(fields []Field) []Field {
+	for _, field := range fields {
+		err, ok := field.Value.(error)
+		if !ok || field.Key != "error" {
+			continue
+		}
+
+		var kind kinder
+		if errors.As(err, &kind) {
+			return append(fields[:len(fields):len(fields)], Field{Key: "kind", Value: kind.KindName()})
+		}
+	}
+
+	return fields
+}
+
+type ILogger interface {
+	Debug(msg string, fields ...Field)
+	Info(msg string, fields ...Field)
+	Warn(msg string, fields ...Field)
+	Erro(msg string, fields ...Field)
+	// With return the logger adds fields to every record
+	With(fields ...Field) ILogger
+}
+
+type holder struct {
+	logger ILogger
+}
+
+var def atomic.Value
+
+func init() {
+	def.Store(holder{logger: NewDebug()})
+}
+
+// Default the logger used when no logger is injected, it is the debug-go adapter until SetDefault
+func Default() ILogger {
+	return def.Load().(holder).logger
+}
+
+func SetDefault(logger ILogger) {
+	def.Store(holder{logger: logger})
+}
+
+// Or return logger, or the default logger when it is nil
+func Or(logger ILogger) ILogger {
+	if logger == nil {
+		return Default()
+	}
+
+	return logger
+}
@@ -0,0 +1,64 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+type kind int
+
+func (k kind) Error() string    { return "kind" }
+func (k kind) KindName() string { return "oversize" }
+
+// kindError is an error with a kind, like connection.Error
+type kindError struct{}
+
+func (kindError) Error() string   { return "packet too large" }
+func (kindError) Unwrap() []error { return []error{kind(1), errors.New("cause")} }
+
+func TestFields(t *testing.T) {
+	var buff bytes.Buffer
+	l := NewSlog(slog.New(slog.NewTextHandler(&buff, nil))).With(FD(3), Trace("t1"))
+	l.Erro("fail", Err(kindError{}))
+	for _, expect := range []string{"fd=3", "trace_id=t1", "kind=oversize", `error="packet too large"`} {
+		if !strings.Contains(buff.String(), expect) {
+			t.Fatal(expect, buff.String())
+		}
+	}
+}
+
+func TestSampler(t *testing.T) {
+	tests := []struct {
+		name   string
+		level  Level
+		expect int
+	}{
+		{"sampled", Level_Info, 4},
+		{"kept", Level_Erro, 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buff bytes.Buffer
+			s := NewSampler(NewSlog(slog.New(slog.NewTextHandler(&buff, nil))), 2, time.Hour).WithKeep(Level_Erro)
+			// every connection has its own budget
+			a, b := s.With(FD(1)), s.With(FD(2))
+			for i := 0; i < 5; i++ {
+				for _, l := range []ILogger{a, b} {
+					if tt.level == Level_Erro {
+						l.Erro("record")
+					} else {
+						l.Info("record")
+					}
+				}
+			}
+			if n := strings.Count(buff.String(), "\n"); n != tt.expect {
+				t.Fatal(n, buff.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package logger
+
+import (
+	"sync"
+	"time"
+)
+
+// Sampler write at most burst records in every interval, the rest are dropped and counted,
+// the count is added to the next written record as the dropped field.
+// Every logger returned by With has its own budget, so a connection logger samples only its own records
+type Sampler struct {
+	logger   ILogger
+	burst    int
+	interval time.Duration
+	minLevel Level // records at or above minLevel are never dropped
+	locker   sync.Mutex
+	start    time.Time
+	count    int
+	dropped  int
+}
+
+func NewSampler(logger ILogger, burst int, interval time.Duration) *Sampler {
+	return &Sampler{logger: logger, burst: burst, interval: interval, minLevel: Level_Erro + 1}
+}
+
+// WithKeep records at or above level are never dropped
+func (s *Sampler) WithKeep(level Level) *Sampler {
+	s.minLevel = level
+	return s
+}
+
+func (s *Sampler) allow(level Level, fields []Field) ([]Field, bool) {
+	if level >= s.minLevel {
+		return fields, true
+	}
+
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	now := time.Now()
+	if now.Sub(s.start) >= s.interval {
+		s.start = now
+		s.count = 0
+	}
+
+	if s.count >= s.burst {
+		s.dropped++
+		return fields, false
+	}
+
+	s.count++
+	if s.dropped > 0 {
+		fields = append(fields[:len(fields):len(fields)], Field{Key: "dropped", Value: s.dropped})
+		s.dropped = 0
+	}
+
+	return fields, true
+}
+
+func (s *Sampler) Debug(msg string, fields ...Field) {
+	if fields, ok := s.allow(Level_Debug, fields); ok {
+		s.logger.Debug(msg, fields...)
+	}
+}
+
+func (s *Sampler) Info(msg string, fields ...Field) {
+	if fields, ok := s.allow(Level_Info, fields); ok {
+		s.logger.Info(msg, fields...)
+	}
+}
+
+func (s *Sampler) Warn(msg string, fields ...Field) {
+	if fields, ok := s.allow(Level_Warn, fields); ok {
+		s.logger.Warn(msg, fields...)
+	}
+}
+
+func (s *Sampler) Erro(msg string, fields ...Field) {
+	if fields, ok := s.allow(Level_Erro, fields); ok {
+		s.logger.Erro(msg, fields...)
+	}
+}
+
+func (s *Sampler) With(fields ...Field) ILogger {
+	return &Sampler{logger: s.logger.With(fields...), burst: s.burst, interval: s.interval, minLevel: s.minLevel}
+}
@@ -0,0 +1,58 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Slog adapt *slog.Logger to ILogger
+type Slog struct {
+	logger *slog.Logger
+}
+
+func NewSlog(logger *slog.Logger) *Slog {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &Slog{logger: logger}
+}
+
+func (s *Slog) log(level slog.Level, msg string, fields []Field) {
+	ctx := context.Background()
+	if !s.logger.Enabled(ctx, level) {
+		return
+	}
+
+	fields = expand(fields)
+	attrs := make([]slog.Attr, len(fields))
+	for i, field := range fields {
+		attrs[i] = slog.Any(field.Key, field.Value)
+	}
+	s.logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+func (s *Slog) Debug(msg string, fields ...Field) {
+	s.log(slog.LevelDebug, msg, fields)
+}
+
+func (s *Slog) Info(msg string, fields ...Field) {
+	s.log(slog.LevelInfo, msg, fields)
+}
+
+func (s *Slog) Warn(msg string, fields ...Field) {
+	s.log(slog.LevelWarn, msg, fields)
+}
+
+func (s *Slog) Erro(msg string, fields ...Field) {
+	s.log(slog.LevelError, msg, fields)
+}
+
+func (s *Slog) With(fields ...Field) ILogger {
+	args := make([]any, len(fields))
+	for i, field := range fields {
+		args[i] = slog.Any(field.Key, field.Value)
+	}
+
+	return &Slog{logger: s.logger.With(args...)}
+}
@@ -0,0 +1,109 @@
+package memnet
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+var Err_Addr_In_Use = errors.New("memnet: address already in use")
+var Err_Refused = errors.New("memnet: connection refused")
+
+// Addr is the address of an in-memory listener or connection
+type Addr string
+
+func (a Addr) Network() string {
+	return "memory"
+}
+
+func (a Addr) String() string {
+	return string(a)
+}
+
+var (
+	listeners sync.Map
+	nextPeer  atomic.Uint64
+)
+
+// Listener accept the connections dialed to its address in the same process,
+// the connections are synchronous pipes built by net.Pipe
+type Listener struct {
+	addr   Addr
+	conns  chan net.Conn
+	done   chan struct{}
+	closer sync.Once
+}
+
+// Listen on address in the process, the address is any unique string
+func Listen(address string) (*Listener, error) {
+	l := &Listener{addr: Addr(address), conns: make(chan net.Conn), done: make(chan struct{})}
+	if _, loaded := listeners.LoadOrStore(address, l); loaded {
+		return nil, Err_Addr_In_Use
+	}
+
+	return l, nil
+}
+
+func (l *Listener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *Listener) Close() error {
+	l.closer.Do(func() {
+		listeners.CompareAndDelete(string(l.addr), l)
+		close(l.done)
+	})
+	return nil
+}
+
+func (l *Listener) Addr() net.Addr {
+	return l.addr
+}
+
+// Dial the listener of address
+func Dial(ctx context.Context, address string) (net.Conn, error) {
+	value, ok := listeners.Load(address)
+	if !ok {
+		return nil, Err_Refused
+	}
+
+	l := value.(*Listener)
+	peer := Addr("memory-peer-" + strconv.FormatUint(nextPeer.Add(1), 10))
+	client, server := net.Pipe()
+	var err error
+	select {
+	case l.conns <- &conn{Conn: server, local: l.addr, remote: peer}:
+		return &conn{Conn: client, local: peer, remote: l.addr}, nil
+	case <-l.done:
+		err = Err_Refused
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	client.Close()
+	server.Close()
+	return nil, err
+}
+
+// conn is the pipe with the addresses of the listener and the dialer
+type conn struct {
+	net.Conn
+	local  Addr
+	remote Addr
+}
+
+func (c *conn) LocalAddr() net.Addr {
+	return c.local
+}
+
+func (c *conn) RemoteAddr() net.Addr {
+	return c.remote
+}
@@ -0,0 +1,268 @@
+package mux
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/kovey/network-go/v2/connection"
+)
+
+var Err_Session_Closed = errors.New("session is closed")
+var Err_Stream_Reset = errors.New("stream is reset")
+var Err_Stream_Closed = errors.New("stream is closed")
+var Err_Window_Exceeded = errors.New("stream flow control window exceeded")
+var Err_Too_Many_Streams = errors.New("too many streams")
+var Err_Stream_ID = connection.NewError(connection.Err_Protocol, errors.New("stream id is not opened by the peer side"))
+var Err_Stream_In_Use = connection.NewError(connection.Err_Protocol, errors.New("stream id is in use"))
+var Err_Window_Overflow = connection.NewError(connection.Err_Protocol, errors.New("stream flow control window overflow"))
+
+// frame is carried in the body of a packet of connection.Kind_Mux:
+// type(1) | stream id(4) | payload, the payload of window update is the increment(4)
+const frame_header_len = 5
+
+const (
+	frame_open   byte = 1
+	frame_data   byte = 2
+	frame_close  byte = 3
+	frame_reset  byte = 4
+	frame_window byte = 5
+)
+
+const default_window = 256 * 1024
+
+// IsFrame report whether the packet is a mux frame
+func IsFrame(packet *connection.Packet) bool {
+	return packet.Kind() == connection.Kind_Mux && len(packet.Body) >= frame_header_len
+}
+
+// Session multiplex logical streams over one connection,
+// the client opens odd stream ids and the server opens even stream ids
+type Session struct {
+	conn       *connection.Connection
+	isClient   bool
+	nextID     uint32
+	streams    map[uint32]*Stream
+	accepts    chan *Stream
+	window     uint32
+	frameSize  int
+	maxStreams int
+	locker     sync.Mutex
+	isClosed   bool
+	done       chan struct{}
+}
+
+// NewSession fails with connection.Err_No_Kind when the header of conn has no kind field
+func NewSession(conn *connection.Connection, isClient bool) (*Session, error) {
+	if !conn.Header().HasKind() {
+		return nil, connection.Err_No_Kind
+	}
+
+	s := &Session{conn: conn, isClient: isClient, nextID: 2, streams: make(map[uint32]*Stream), accepts: make(chan *Stream, 64), window: default_window, frameSize: 4096, maxStreams: 1024, done: make(chan struct{})}
+	if isClient {
+		s.nextID = 1
+	}
+
+	conn.OnClose(func(*connection.Connection) {
+		s.Close()
+	})
+	return s, nil
+}
+
+// WithWindow initial flow control window of every stream
+func (s *Session) WithWindow(window uint32) *Session {
+	s.window = window
+	return s
+}
+
+// WithFrameSize max payload length of a data frame, it is ignored when size <= 0
+// and capped at the room left in a packet of the connection
+func (s *Session) WithFrameSize(size int) *Session {
+	if size > 0 {
+		s.frameSize = size
+	}
+	return s
+}
+
+// FrameSize max payload length of a data frame, at least 1
+func (s *Session) FrameSize() int {
+	return max(min(s.frameSize, s.conn.MaxBodyLen()-frame_header_len), 1)
+}
+
+func (s *Session) WithMaxStreams(count int) *Session {
+	s.maxStreams = count
+	return s
+}
+
+func (s *Session) Connection() *connection.Connection {
+	return s.conn
+}
+
+func (s *Session) send(typ byte, id uint32, payload []byte) error {
+	body := make([]byte, frame_header_len+len(payload))
+	body[0] = typ
+	binary.BigEndian.PutUint32(body[1:], id)
+	copy(body[frame_header_len:], payload)
+	return s.conn.WriteFrame(connection.Kind_Mux, body)
+}
+
+func (s *Session) sendWindow(id uint32, increment uint32) error {
+	var payload [4]byte
+	binary.BigEndian.PutUint32(payload[:], increment)
+	return s.send(frame_window, id, payload[:])
+}
+
+// Open a new stream to the peer
+func (s *Session) Open() (*Stream, error) {
+	s.locker.Lock()
+	if s.isClosed {
+		s.locker.Unlock()
+		return nil, Err_Session_Closed
+	}
+	if len(s.streams) >= s.maxStreams {
+		s.locker.Unlock()
+		return nil, Err_Too_Many_Streams
+	}
+
+	id := s.nextID
+	s.nextID += 2
+	stream := newStream(s, id)
+	s.streams[id] = stream
+	s.locker.Unlock()
+
+	if err := s.send(frame_open, id, nil); err != nil {
+		s.remove(id)
+		return nil, err
+	}
+
+	return stream, nil
+}
+
+// Accept wait for the next stream opened by the peer
+func (s *Session) Accept() (*Stream, error) {
+	select {
+	case stream := <-s.accepts:
+		return stream, nil
+	case <-s.done:
+		return nil, Err_Session_Closed
+	}
+}
+
+// Handle consume the packet when it is a mux frame, handled is false for other packets,
+// the connection should be closed when err is a connection.Err_Protocol
+func (s *Session) Handle(packet *connection.Packet) (handled bool, err error) {
+	if !IsFrame(packet) {
+		return false, nil
+	}
+
+	body := packet.Body
+	typ := body[0]
+	id := binary.BigEndian.Uint32(body[1:])
+	payload := body[frame_header_len:]
+
+	if typ == frame_open {
+		return true, s.accept(id)
+	}
+
+	s.locker.Lock()
+	stream := s.streams[id]
+	s.locker.Unlock()
+	if stream == nil {
+		if typ != frame_reset {
+			s.send(frame_reset, id, nil)
+		}
+		return true, nil
+	}
+
+	switch typ {
+	case frame_data:
+		if err := stream.push(payload); err != nil {
+			stream.reset(err)
+			s.send(frame_reset, id, nil)
+			return true, err
+		}
+	case frame_window:
+		if len(payload) >= 4 {
+			if err := stream.grow(binary.BigEndian.Uint32(payload)); err != nil {
+				stream.reset(err)
+				s.send(frame_reset, id, nil)
+				return true, err
+			}
+		}
+	case frame_close:
+		stream.remoteClose()
+	case frame_reset:
+		stream.reset(Err_Stream_Reset)
+	}
+
+	return true, nil
+}
+
+// isPeer report whether id is in the range of the peer side, the client opens the odd ids
+func (s *Session) isPeer(id uint32) bool {
+	return id != 0 && (id%2 == 1) != s.isClient
+}
+
+func (s *Session) accept(id uint32) error {
+	if !s.isPeer(id) {
+		return fmt.Errorf("%w: %d", Err_Stream_ID, id)
+	}
+
+	s.locker.Lock()
+	if s.isClosed {
+		s.locker.Unlock()
+		return Err_Session_Closed
+	}
+	if _, ok := s.streams[id]; ok {
+		s.locker.Unlock()
+		return fmt.Errorf("%w: %d", Err_Stream_In_Use, id)
+	}
+	if len(s.streams) >= s.maxStreams {
+		s.locker.Unlock()
+		s.send(frame_reset, id, nil)
+		return Err_Too_Many_Streams
+	}
+
+	stream := newStream(s, id)
+	s.streams[id] = stream
+	s.locker.Unlock()
+
+	select {
+	case s.accepts <- stream:
+		return nil
+	default:
+		stream.reset(Err_Too_Many_Streams)
+		s.send(frame_reset, id, nil)
+		return Err_Too_Many_Streams
+	}
+}
+
+func (s *Session) remove(id uint32) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	delete(s.streams, id)
+}
+
+// Close reset all streams of the session
+func (s *Session) Close() error {
+	s.locker.Lock()
+	if s.isClosed {
+		s.locker.Unlock()
+		return nil
+	}
+
+	s.isClosed = true
+	close(s.done)
+	streams := make([]*Stream, 0, len(s.streams))
+	for _, stream := range s.streams {
+		streams = append(streams, stream)
+	}
+	s.locker.Unlock()
+
+	for _, stream := range streams {
+		stream.reset(Err_Session_Closed)
+	}
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package mux
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"testing"
+
+	"github.com/kovey/network-go/v2/connection"
+)
+
+// newConn the connection with the kind at offset 4 of the header
+func newConn(fd uint64, conn net.Conn) *connection.Connection {
+	return connection.NewConnection(fd, conn).WithHeaderLen(5).WithKind(4).WithMaxLen(10000)
+}
+
+func pump(c *connection.Connection, s *Session) {
+	for {
+		p, err := c.Read()
+		if err != nil {
+			return
+		}
+		s.Handle(p)
+	}
+}
+
+// frame encode the mux frame of the kind header
+func frame(typ byte, id uint32, payload []byte) *connection.Packet {
+	body := make([]byte, frame_header_len+len(payload))
+	body[0] = typ
+	binary.BigEndian.PutUint32(body[1:], id)
+	copy(body[frame_header_len:], payload)
+	p, _ := connection.EncodeFrame(connection.Kind_Mux, body, newConn(0, nil).Header())
+	return p
+}
+
+func TestSessionEcho(t *testing.T) {
+	a, b := net.Pipe()
+	ca, cb := newConn(1, a), newConn(2, b)
+	defer ca.Close()
+	defer cb.Close()
+	cli, _ := NewSession(ca, true)
+	srv, _ := NewSession(cb, false)
+	cli.WithWindow(10000)
+	srv.WithWindow(10000).WithFrameSize(1 << 20)
+	go pump(ca, cli)
+	go pump(cb, srv)
+	go func() {
+		for {
+			st, err := srv.Accept()
+			if err != nil {
+				return
+			}
+			go func() { io.Copy(st, st); st.Close() }()
+		}
+	}()
+
+	var _ net.Conn = (*Stream)(nil)
+	done := make(chan bool)
+	for i := 0; i < 5; i++ {
+		go func(i int) {
+			defer func() { done <- true }()
+			st, err := cli.Open()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if st.ID()%2 != 1 {
+				t.Error(st.ID())
+			}
+			payload := bytes.Repeat([]byte{byte(i)}, 200000)
+			go func() { st.Write(payload); st.Close() }()
+			got, err := io.ReadAll(st)
+			if err != nil || !bytes.Equal(got, payload) {
+				t.Error(i, err, len(got))
+			}
+		}(i)
+	}
+	for i := 0; i < 5; i++ {
+		<-done
+	}
+}
+
+func TestSessionAccept(t *testing.T) {
+	tests := []struct {
+		name     string
+		isClient bool
+		ids      []uint32
+		err      error
+	}{
+		{"client accepts even", true, []uint32{2}, nil},
+		{"client rejects odd", true, []uint32{1}, Err_Stream_ID},
+		{"server accepts odd", false, []uint32{1, 3}, nil},
+		{"server rejects even", false, []uint32{2}, Err_Stream_ID},
+		{"zero", false, []uint32{0}, Err_Stream_ID},
+		{"in use", false, []uint32{1, 1}, Err_Stream_In_Use},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := net.Pipe()
+			defer a.Close()
+			defer b.Close()
+			go io.Copy(io.Discard, b)
+			s, err := NewSession(newConn(1, a), tt.isClient)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var handled bool
+			for _, id := range tt.ids {
+				handled, err = s.Handle(frame(frame_open, id, nil))
+				if !handled {
+					t.Fatal(id)
+				}
+			}
+			if !errors.Is(err, tt.err) {
+				t.Fatal(err)
+			}
+			if tt.err != nil && !errors.Is(err, connection.Err_Protocol) {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestSessionWindowOverflow(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+	go io.Copy(io.Discard, b)
+	s, _ := NewSession(newConn(1, a), true)
+	st, err := s.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var increment [4]byte
+	binary.BigEndian.PutUint32(increment[:], math.MaxUint32)
+	if _, err := s.Handle(frame(frame_window, st.ID(), increment[:])); !errors.Is(err, Err_Window_Overflow) {
+		t.Fatal(err)
+	}
+	if _, err := st.Write([]byte("x")); !errors.Is(err, Err_Window_Overflow) {
+		t.Fatal(err)
+	}
+}
+
+func TestSessionNoKind(t *testing.T) {
+	if _, err := NewSession(connection.NewConnection(1, nil), true); !errors.Is(err, connection.Err_No_Kind) {
+		t.Fatal(err)
+	}
+
+	// the application body starts with the old magic is not a frame
+	p := connection.NewPacket([]byte{0x4b, 0x4d, frame_open, 0, 0, 0, 1}, newConn(0, nil).Header())
+	s, _ := NewSession(newConn(1, nil), false)
+	if handled, err := s.Handle(p); handled || err != nil {
+		t.Fatal(handled, err)
+	}
+}
@@ -0,0 +1,247 @@
+package mux
+
+import (
+	"io"
+	"math"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Stream is a logical net.Conn over the connection of the session
+type Stream struct {
+	s             *Session
+	id            uint32
+	locker        sync.Mutex
+	cond          *sync.Cond
+	chunks        [][]byte
+	recvWindow    uint32
+	consumed      uint32
+	sendWindow    uint32
+	localClosed   bool
+	remoteClosed  bool
+	err           error
+	readDeadline  time.Time
+	writeDeadline time.Time
+}
+
+func newStream(s *Session, id uint32) *Stream {
+	stream := &Stream{s: s, id: id, recvWindow: s.window, sendWindow: s.window}
+	stream.cond = sync.NewCond(&stream.locker)
+	return stream
+}
+
+func (s *Stream) ID() uint32 {
+	return s.id
+}
+
+// wait must be called with locker held, it returns os.ErrDeadlineExceeded after deadline
+func (s *Stream) wait(deadline time.Time) error {
+	if !deadline.IsZero() {
+		d := time.Until(deadline)
+		if d <= 0 {
+			return os.ErrDeadlineExceeded
+		}
+
+		timer := time.AfterFunc(d, func() {
+			s.locker.Lock()
+			s.cond.Broadcast()
+			s.locker.Unlock()
+		})
+		defer timer.Stop()
+	}
+
+	s.cond.Wait()
+	if !deadline.IsZero() && !time.Now().Before(deadline) {
+		return os.ErrDeadlineExceeded
+	}
+
+	return nil
+}
+
+func (s *Stream) push(payload []byte) error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	if s.err != nil || s.remoteClosed {
+		return nil
+	}
+	if uint32(len(payload)) > s.recvWindow {
+		return Err_Window_Exceeded
+	}
+
+	s.recvWindow -= uint32(len(payload))
+	if len(payload) > 0 {
+		s.chunks = append(s.chunks, append([]byte(nil), payload...))
+	}
+	s.cond.Broadcast()
+	return nil
+}
+
+// grow the send window, it fails when the window overflows
+func (s *Stream) grow(increment uint32) error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	if s.sendWindow > math.MaxUint32-increment {
+		return Err_Window_Overflow
+	}
+
+	s.sendWindow += increment
+	s.cond.Broadcast()
+	return nil
+}
+
+func (s *Stream) remoteClose() {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.remoteClosed = true
+	if s.localClosed {
+		s.s.remove(s.id)
+	}
+	s.cond.Broadcast()
+}
+
+func (s *Stream) reset(err error) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	if s.err == nil {
+		s.err = err
+	}
+	s.chunks = nil
+	s.s.remove(s.id)
+	s.cond.Broadcast()
+}
+
+func (s *Stream) Read(p []byte) (int, error) {
+	s.locker.Lock()
+	for len(s.chunks) == 0 {
+		if s.err != nil {
+			s.locker.Unlock()
+			return 0, s.err
+		}
+		if s.remoteClosed {
+			s.locker.Unlock()
+			return 0, io.EOF
+		}
+		if err := s.wait(s.readDeadline); err != nil {
+			s.locker.Unlock()
+			return 0, err
+		}
+	}
+
+	n := copy(p, s.chunks[0])
+	if n == len(s.chunks[0]) {
+		s.chunks = s.chunks[1:]
+	} else {
+		s.chunks[0] = s.chunks[0][n:]
+	}
+
+	var increment uint32
+	s.consumed += uint32(n)
+	if s.consumed >= s.s.window/2 && !s.remoteClosed {
+		increment = s.consumed
+		s.recvWindow += increment
+		s.consumed = 0
+	}
+	s.locker.Unlock()
+
+	if increment > 0 {
+		s.s.sendWindow(s.id, increment)
+	}
+
+	return n, nil
+}
+
+// Write block while the flow control window of the peer is exhausted
+func (s *Stream) Write(p []byte) (int, error) {
+	written := 0
+	s.locker.Lock()
+	for len(p) > 0 {
+		for s.sendWindow == 0 && s.err == nil && !s.localClosed {
+			if err := s.wait(s.writeDeadline); err != nil {
+				s.locker.Unlock()
+				return written, err
+			}
+		}
+		if s.err != nil {
+			s.locker.Unlock()
+			return written, s.err
+		}
+		if s.localClosed {
+			s.locker.Unlock()
+			return written, Err_Stream_Closed
+		}
+
+		n := min(len(p), int(s.sendWindow), s.s.FrameSize())
+		s.sendWindow -= uint32(n)
+		s.locker.Unlock()
+
+		if err := s.s.send(frame_data, s.id, p[:n]); err != nil {
+			return written, err
+		}
+
+		written += n
+		p = p[n:]
+		s.locker.Lock()
+	}
+	s.locker.Unlock()
+
+	return written, nil
+}
+
+// Close half close the stream, the peer reads io.EOF after the buffered data
+func (s *Stream) Close() error {
+	s.locker.Lock()
+	if s.localClosed || s.err != nil {
+		s.locker.Unlock()
+		return nil
+	}
+
+	s.localClosed = true
+	if s.remoteClosed {
+		s.s.remove(s.id)
+	}
+	s.cond.Broadcast()
+	s.locker.Unlock()
+
+	return s.s.send(frame_close, s.id, nil)
+}
+
+// Reset abort the stream in both directions
+func (s *Stream) Reset() error {
+	s.reset(Err_Stream_Reset)
+	return s.s.send(frame_reset, s.id, nil)
+}
+
+func (s *Stream) LocalAddr() net.Addr {
+	return s.s.conn.LocalAddr()
+}
+
+func (s *Stream) RemoteAddr() net.Addr {
+	return s.s.conn.RemoteAddr()
+}
+
+func (s *Stream) SetDeadline(t time.Time) error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.readDeadline = t
+	s.writeDeadline = t
+	s.cond.Broadcast()
+	return nil
+}
+
+func (s *Stream) SetReadDeadline(t time.Time) error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.readDeadline = t
+	s.cond.Broadcast()
+	return nil
+}
+
+func (s *Stream) SetWriteDeadline(t time.Time) error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	s.writeDeadline = t
+	s.cond.Broadcast()
+	return nil
+}
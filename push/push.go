@@ -0,0 +1,51 @@
+package push
+
+import (
+	"encoding/binary"
+
+	"github.com/kovey/network-go/v2/connection"
+)
+
+// frame is carried in the body of a packet of connection.Kind_Push:
+// type(1) | message id(8) | payload
+const frame_header_len = 9
+
+type Type byte
+
+const (
+	Type_Message Type = 1
+	Type_Ack     Type = 2
+)
+
+// IsFrame report whether the packet is a push frame
+func IsFrame(packet *connection.Packet) bool {
+	return packet.Kind() == connection.Kind_Push && len(packet.Body) >= frame_header_len
+}
+
+func encode(t Type, id uint64, payload []byte) []byte {
+	body := make([]byte, frame_header_len+len(payload))
+	body[0] = byte(t)
+	binary.BigEndian.PutUint64(body[1:], id)
+	copy(body[frame_header_len:], payload)
+	return body
+}
+
+// Message the body of the pushed message, it is written by connection.Kind_Push
+func Message(id uint64, payload []byte) []byte {
+	return encode(Type_Message, id, payload)
+}
+
+// Ack the body of the ack, it is written by connection.Kind_Push
+func Ack(id uint64) []byte {
+	return encode(Type_Ack, id, nil)
+}
+
+// Decode ok is false when the packet is not a push frame
+func Decode(packet *connection.Packet) (t Type, id uint64, payload []byte, ok bool) {
+	if !IsFrame(packet) {
+		return 0, 0, nil, false
+	}
+
+	body := packet.Body
+	return Type(body[0]), binary.BigEndian.Uint64(body[1:]), body[frame_header_len:], true
+}
@@ -0,0 +1,45 @@
+package resume
+
+import "github.com/kovey/network-go/v2/connection"
+
+// frame is carried in the body of a packet of connection.Kind_Resume:
+// type(1) | token
+const frame_header_len = 1
+
+type Type byte
+
+const (
+	Type_Issue  Type = 1 // server to client, the token of the session
+	Type_Resume Type = 2 // client to server, resume the session of the token
+)
+
+// IsFrame report whether the packet is a resume frame
+func IsFrame(packet *connection.Packet) bool {
+	return packet.Kind() == connection.Kind_Resume && len(packet.Body) >= frame_header_len
+}
+
+func encode(t Type, token string) []byte {
+	body := make([]byte, frame_header_len+len(token))
+	body[0] = byte(t)
+	copy(body[frame_header_len:], token)
+	return body
+}
+
+// Issue the body of the issued token, it is written by connection.Kind_Resume
+func Issue(token string) []byte {
+	return encode(Type_Issue, token)
+}
+
+// Resume the body of the resume request, it is written by connection.Kind_Resume
+func Resume(token string) []byte {
+	return encode(Type_Resume, token)
+}
+
+// Decode ok is false when the packet is not a resume frame
+func Decode(packet *connection.Packet) (t Type, token string, ok bool) {
+	if !IsFrame(packet) {
+		return 0, "", false
+	}
+
+	return Type(packet.Body[0]), string(packet.Body[frame_header_len:]), true
+}
@@ -0,0 +1,265 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kovey/network-go/v2/connection"
+	"github.com/kovey/network-go/v2/logger"
+)
+
+var Err_Admin_Token = errors.New("admin endpoint on a non-loopback address requires a token")
+
+// ConnInfo is the live state of a connection shown by the admin endpoint
+type ConnInfo struct {
+	FD         uint64            `json:"fd"`
+	Remote     string            `json:"remote"`
+	Identity   string            `json:"identity,omitempty"`
+	Version    uint16            `json:"version,omitempty"`
+	Features   string            `json:"features,omitempty"`
+	ConnectAt  time.Time         `json:"connect_at"`
+	LastActive time.Time         `json:"last_active"`
+	Stats      connection.Stats  `json:"stats"`
+	Groups     []string          `json:"groups,omitempty"`
+	Attrs      map[string]string `json:"attrs,omitempty"`
+}
+
+func (s *Server) connInfo(conn *connection.Connection, detail bool) ConnInfo {
+	info := ConnInfo{FD: conn.FD(), ConnectAt: conn.ConnectTime(), LastActive: conn.LastActiveTime(), Stats: conn.Stats()}
+	info.Version, info.Features = conn.Version(), conn.Negotiated().Features.String()
+	if addr := conn.RemoteAddr(); addr != nil {
+		info.Remote = addr.String()
+	}
+	if identity := conn.Identity(); identity != nil {
+		info.Identity = fmt.Sprint(identity)
+	}
+	if !detail {
+		return info
+	}
+
+	info.Groups = s.GroupsOf(conn)
+	sort.Strings(info.Groups)
+	info.Attrs = make(map[string]string)
+	conn.Range(func(key string, value any) bool {
+		if !strings.HasPrefix(key, "ko.network.") {
+			info.Attrs[key] = fmt.Sprint(value)
+		}
+		return true
+	})
+	return info
+}
+
+// Conns the live connections ordered by fd
+func (s *Server) Conns() []*connection.Connection {
+	var conns []*connection.Connection
+	s.conns.Range(func(_, value any) bool {
+		if conn, ok := value.(*connection.Connection); ok {
+			conns = append(conns, conn)
+		}
+		return true
+	})
+	sort.Slice(conns, func(i, j int) bool {
+		return conns[i].FD() < conns[j].FD()
+	})
+	return conns
+}
+
+func (s *Server) conn(fd uint64) *connection.Connection {
+	value, ok := s.conns.Load(fd)
+	if !ok {
+		return nil
+	}
+
+	conn, _ := value.(*connection.Connection)
+	return conn
+}
+
+// Config the settings of the server and the service
+func (s *Server) Config() map[string]any {
+	config := map[string]any{"host": s.host, "port": s.port, "maintain": s.IsMaintain(), "service": fmt.Sprintf("%T", s.service)}
+	if s.authenticator != nil {
+		config["auth_timeout"] = s.authTimeout.String()
+	}
+	if s.dispatcher != nil {
+		config["dispatcher"] = fmt.Sprintf("%T", s.dispatcher)
+	}
+	if s.outbox != nil {
+		config["outbox_window"] = s.outbox.window.String()
+		config["outbox_max_pending"] = s.outbox.maxPending
+	}
+	if s.sessions != nil {
+		config["session_grace"] = s.sessions.grace.String()
+	}
+	if service, ok := s.service.(interface{ settings() map[string]any }); ok {
+		for key, value := range service.settings() {
+			config[key] = value
+		}
+	}
+
+	return config
+}
+
+// AdminHandler the http handler of the admin endpoint, token is required as the bearer token when it is not empty,
+// only the loopback clients are served when it is empty:
+//
+//	GET  /connections                 list the connections
+//	GET  /connections/{fd}            the details of the connection
+//	POST /connections/{fd}/kick       kick the connection
+//	GET  /groups/{group}              the connections of the group
+//	POST /groups/{group}/kick         kick all connections of the group
+//	GET  /maintain                    the maintain state
+//	POST /maintain?on=true|false      toggle maintain
+//	GET  /config                      the settings of the server
+func (s *Server) AdminHandler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /connections", func(w http.ResponseWriter, r *http.Request) {
+		conns := s.Conns()
+		infos := make([]ConnInfo, len(conns))
+		for i, conn := range conns {
+			infos[i] = s.connInfo(conn, false)
+		}
+		writeJson(w, http.StatusOK, infos)
+	})
+	mux.HandleFunc("GET /connections/{fd}", func(w http.ResponseWriter, r *http.Request) {
+		if conn := s.pathConn(w, r); conn != nil {
+			writeJson(w, http.StatusOK, s.connInfo(conn, true))
+		}
+	})
+	mux.HandleFunc("POST /connections/{fd}/kick", func(w http.ResponseWriter, r *http.Request) {
+		conn := s.pathConn(w, r)
+		if conn == nil {
+			return
+		}
+
+		s.log().Warn("admin kick connection", logger.FD(conn.FD()), logger.Remote(conn.RemoteAddr()))
+		s.Kick(conn)
+		writeJson(w, http.StatusOK, map[string]any{"kicked": 1})
+	})
+	mux.HandleFunc("GET /groups/{group}", func(w http.ResponseWriter, r *http.Request) {
+		members := s.Members(r.PathValue("group"))
+		sort.Slice(members, func(i, j int) bool {
+			return members[i].FD() < members[j].FD()
+		})
+		infos := make([]ConnInfo, len(members))
+		for i, conn := range members {
+			infos[i] = s.connInfo(conn, false)
+		}
+		writeJson(w, http.StatusOK, infos)
+	})
+	mux.HandleFunc("POST /groups/{group}/kick", func(w http.ResponseWriter, r *http.Request) {
+		group := r.PathValue("group")
+		members := s.Members(group)
+		s.log().Warn("admin kick group", logger.F("group", group), logger.F("count", len(members)))
+		for _, conn := range members {
+			s.Kick(conn)
+		}
+		writeJson(w, http.StatusOK, map[string]any{"kicked": len(members)})
+	})
+	mux.HandleFunc("GET /maintain", func(w http.ResponseWriter, r *http.Request) {
+		writeJson(w, http.StatusOK, map[string]any{"maintain": s.IsMaintain()})
+	})
+	mux.HandleFunc("POST /maintain", func(w http.ResponseWriter, r *http.Request) {
+		on, err := strconv.ParseBool(r.URL.Query().Get("on"))
+		if err != nil {
+			writeJson(w, http.StatusBadRequest, map[string]any{"error": "on must be true or false"})
+			return
+		}
+
+		if on {
+			s.Maintain()
+		} else {
+			s.EndMaintain()
+		}
+		writeJson(w, http.StatusOK, map[string]any{"maintain": s.IsMaintain()})
+	})
+	mux.HandleFunc("GET /config", func(w http.ResponseWriter, r *http.Request) {
+		writeJson(w, http.StatusOK, s.Config())
+	})
+
+	expect := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			if host, _, _ := net.SplitHostPort(r.RemoteAddr); !isLoopback(host) {
+				writeJson(w, http.StatusForbidden, map[string]any{"error": "forbidden"})
+				return
+			}
+		} else if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expect) != 1 {
+			writeJson(w, http.StatusUnauthorized, map[string]any{"error": "unauthorized"})
+			return
+		}
+
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// ServeAdmin serve the admin endpoint on address until ctx is cancelled,
+// it fails with Err_Admin_Token when token is empty and the address is not loopback
+func (s *Server) ServeAdmin(ctx context.Context, address, token string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if token == "" && !isLoopback(host) {
+		return fmt.Errorf("%w: %s", Err_Admin_Token, address)
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{Handler: s.AdminHandler(token), ReadHeaderTimeout: 10 * time.Second}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			server.Close()
+		case <-done:
+		}
+	}()
+
+	s.log().Info("admin listen", logger.F("address", listener.Addr().String()))
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (s *Server) pathConn(w http.ResponseWriter, r *http.Request) *connection.Connection {
+	fd, err := strconv.ParseUint(r.PathValue("fd"), 10, 64)
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, map[string]any{"error": "fd must be an unsigned integer"})
+		return nil
+	}
+
+	conn := s.conn(fd)
+	if conn == nil {
+		writeJson(w, http.StatusNotFound, map[string]any{"error": Err_Conn_Not_Found.Error()})
+	}
+	return conn
+}
+
+func writeJson(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kovey/network-go/v2/client"
+	"github.com/kovey/network-go/v2/connection"
+)
+
+// roomHandler set the role and join the room on connect
+type roomHandler struct {
+	countHandler
+	s *Server
+}
+
+func (h *roomHandler) Connect(conn *connection.Connection) error {
+	conn.Set("role", "admin")
+	h.s.Join("room", conn)
+	return h.countHandler.Connect(conn)
+}
+
+func TestAdminHandler(t *testing.T) {
+	s := NewServer("admin", 1).WithService(NewMemoryService(10))
+	h := &roomHandler{s: s}
+	s.WithHandler(h)
+	serveTest(t, s)
+	for i := 0; i < 2; i++ {
+		tcp := client.NewMemory()
+		if err := tcp.Dial("admin", 1); err != nil {
+			t.Fatal(err)
+		}
+		defer tcp.Connection().Close()
+	}
+	waitFor(t, func() bool { return h.connects.Load() == 2 })
+	fd := s.Conns()[0].FD()
+
+	tests := []struct {
+		name     string
+		token    string
+		auth     string
+		remote   string
+		method   string
+		path     string
+		status   int
+		contains string
+	}{
+		{"list", "tok", "Bearer tok", "10.0.0.1:1", "GET", "/connections", 200, `"fd"`},
+		{"detail", "tok", "Bearer tok", "10.0.0.1:1", "GET", "/connections/" + strconv.FormatUint(fd, 10), 200, `"room"`},
+		{"not found", "tok", "Bearer tok", "10.0.0.1:1", "GET", "/connections/99", 404, "not exists"},
+		{"bad fd", "tok", "Bearer tok", "10.0.0.1:1", "GET", "/connections/x", 400, "unsigned"},
+		{"no token", "tok", "", "127.0.0.1:1", "GET", "/config", 401, "unauthorized"},
+		{"wrong token", "tok", "Bearer tox", "127.0.0.1:1", "GET", "/config", 401, "unauthorized"},
+		{"loopback", "", "", "127.0.0.1:1", "GET", "/maintain", 200, "false"},
+		{"remote without token", "", "", "10.0.0.1:1", "GET", "/config", 403, "forbidden"},
+		{"maintain", "tok", "Bearer tok", "10.0.0.1:1", "POST", "/maintain?on=true", 200, "true"},
+		{"bad maintain", "tok", "Bearer tok", "10.0.0.1:1", "POST", "/maintain?on=x", 400, "true or false"},
+		{"kick group", "tok", "Bearer tok", "10.0.0.1:1", "POST", "/groups/room/kick", 200, `"kicked":2`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			r.RemoteAddr = tt.remote
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			s.AdminHandler(tt.token).ServeHTTP(w, r)
+			if w.Code != tt.status || !strings.Contains(w.Body.String(), tt.contains) {
+				t.Fatal(w.Code, w.Body.String())
+			}
+		})
+	}
+	waitFor(t, func() bool { return h.closes.Load() == 2 })
+}
+
+func TestServeAdmin(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		token   string
+		err     error
+	}{
+		{"any address without token", "0.0.0.0:0", "", Err_Admin_Token},
+		{"empty host without token", ":0", "", Err_Admin_Token},
+		{"loopback without token", "127.0.0.1:0", "", nil},
+		{"localhost without token", "localhost:0", "", nil},
+		{"any address with token", "0.0.0.0:0", "tok", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			if err := NewServer("admin", 2).ServeAdmin(ctx, tt.address, tt.token); !errors.Is(err, tt.err) {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kovey/network-go/v2/connection"
+)
+
+// Err_Auth_Continue returned by IAuthenticator when more packets are required
+var Err_Auth_Continue = errors.New("authenticate need more packets")
+var Err_Auth_Timeout = connection.NewError(connection.Err_Idle_Timeout, errors.New("authenticate timeout"))
+var Err_Auth_Rejected = connection.NewError(connection.Err_Protocol, errors.New("authenticate rejected"))
+
+type IAuthenticator interface {
+	// Authenticate return the identity of the connection or an error to reject it
+	Authenticate(*Context) (any, error)
+}
+
+func (s *Server) WithAuthenticator(auth IAuthenticator, timeout time.Duration) *Server {
+	s.authenticator = auth
+	s.authTimeout = timeout
+	return s
+}
+
+// WithAuthReject build the packet sent to the connection before it is closed by authenticate failure
+func (s *Server) WithAuthReject(reject func(*connection.Connection, error) []byte) *Server {
+	s.authReject = reject
+	return s
+}
+
+func (s *Server) authenticate(conn *connection.Connection) error {
+	if s.authenticator == nil {
+		return nil
+	}
+
+	var timeout <-chan time.Time
+	if s.authTimeout > 0 {
+		timer := time.NewTimer(s.authTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	first := true
+	for {
+		select {
+		case pbuf, ok := <-conn.Packets():
+			if !ok {
+				if err := conn.Err(); err != nil {
+					return err
+				}
+				return connection.Err_Closed
+			}
+
+			// the resume token is not a credential, it is resumed after the authenticator admitted the connection
+			held := s.holdResume(pbuf, conn, first)
+			first = false
+			if held {
+				continue
+			}
+
+			identity, err := s.authPacket(pbuf, conn)
+			if err == Err_Auth_Continue {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if identity == nil {
+				return Err_Auth_Rejected
+			}
+
+			conn.WithIdentity(identity)
+			return nil
+		case <-timeout:
+			return Err_Auth_Timeout
+		}
+	}
+}
+
+func (s *Server) authPacket(data *connection.Packet, conn *connection.Connection) (identity any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", Err_Auth_Rejected, r)
+		}
+	}()
+	context := NewContext(conn.Context())
+	defer context.Drop()
+
+	context.Conn = conn
+	context.Data = data
+	return s.authenticator.Authenticate(context)
+}
+
+func (s *Server) reject(conn *connection.Connection, err error) {
+	if s.authReject == nil {
+		return
+	}
+
+	if pack := s.authReject(conn, err); pack != nil {
+		conn.Write(pack)
+	}
+}
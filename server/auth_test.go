@@ -0,0 +1,80 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/kovey/network-go/v2/client"
+	"github.com/kovey/network-go/v2/connection"
+)
+
+type tokenAuth struct{}
+
+func (tokenAuth) Authenticate(ctx *Context) (any, error) {
+	if string(ctx.Data.Body) != "ok" {
+		return nil, Err_Auth_Rejected
+	}
+
+	return "user", nil
+}
+
+func dialMemory(t *testing.T, port int) *connection.Connection {
+	t.Helper()
+	tcp := client.NewMemory()
+	if err := tcp.Dial("auth", port); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tcp.Connection().Close() })
+	return tcp.Connection()
+}
+
+func TestAuthAdmitted(t *testing.T) {
+	h := &countHandler{}
+	s := NewServer("auth", 1).WithService(NewMemoryService(10)).WithHandler(h).WithAuthenticator(tokenAuth{}, time.Second)
+	serveTest(t, s)
+
+	conn := dialMemory(t, 1)
+	time.Sleep(50 * time.Millisecond)
+	if len(s.Conns()) != 0 {
+		t.Fatal("unauthenticated connection is stored")
+	}
+	if err := s.Send([]byte("x"), 1); !errors.Is(err, Err_Conn_Not_Found) {
+		t.Fatal(err)
+	}
+
+	conn.Write(connection.NewPacket([]byte("ok"), conn.Header()).Bytes())
+	waitFor(t, func() bool { return h.connects.Load() == 1 })
+	if conns := s.Conns(); len(conns) != 1 || conns[0].Identity() != "user" {
+		t.Fatal(conns)
+	}
+
+	s.Shutdown()
+	if h.closes.Load() != 1 {
+		t.Fatal(h.closes.Load())
+	}
+}
+
+func TestAuthRejected(t *testing.T) {
+	h := &countHandler{}
+	s := NewServer("auth", 2).WithService(NewMemoryService(10)).WithHandler(h).WithAuthenticator(tokenAuth{}, time.Second)
+	serveTest(t, s)
+
+	conn := dialMemory(t, 2)
+	conn.Write(connection.NewPacket([]byte("bad"), conn.Header()).Bytes())
+	if _, err := conn.Read(); !errors.Is(err, io.EOF) {
+		t.Fatal(err)
+	}
+
+	// the pending connection is closed by shutdown
+	pending := dialMemory(t, 2)
+	time.Sleep(50 * time.Millisecond)
+	s.Shutdown()
+	if _, err := pending.Read(); !errors.Is(err, io.EOF) {
+		t.Fatal(err)
+	}
+	if h.connects.Load() != 0 || h.closes.Load() != 0 {
+		t.Fatal(h.connects.Load(), h.closes.Load())
+	}
+}
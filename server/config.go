@@ -0,0 +1,170 @@
+package server
+
+import (
+	"github.com/kovey/network-go/v2/config"
+)
+
+// ServiceConfig is the config of TcpService
+type ServiceConfig struct {
+	ConnMax     int                `yaml:"conn_max" json:"conn_max"`
+	Framing     config.Framing     `yaml:"framing" json:"framing"`
+	Limits      config.Limits      `yaml:"limits" json:"limits"`
+	Timeouts    config.Timeouts    `yaml:"timeouts" json:"timeouts"`
+	MaxIdleTime config.Duration    `yaml:"max_idle_time" json:"max_idle_time"` // the connection is closed when it is idle longer, 0 is disabled
+	Secure      config.Secure      `yaml:"secure" json:"secure"`
+	TLS         config.TLS         `yaml:"tls" json:"tls"`
+	LogSampling config.Sampling    `yaml:"log_sampling" json:"log_sampling"`
+	Negotiation config.Negotiation `yaml:"negotiation" json:"negotiation"`
+}
+
+// OutboxConfig is the config of reliable push, 0 window is disabled
+type OutboxConfig struct {
+	Window     config.Duration `yaml:"window" json:"window"`
+	MaxPending int             `yaml:"max_pending" json:"max_pending"`
+}
+
+// Config is the config of Server, it is loaded by LoadConfig and applied by NewServerFrom
+type Config struct {
+	Host         string          `yaml:"host" json:"host"`
+	Port         int             `yaml:"port" json:"port"`
+	Service      ServiceConfig   `yaml:"service" json:"service"`
+	SessionGrace config.Duration `yaml:"session_grace" json:"session_grace"` // resumable sessions are kept for grace, 0 is disabled
+	Outbox       OutboxConfig    `yaml:"outbox" json:"outbox"`
+}
+
+// DefaultConfig is the same as the defaults of NewTcpService
+func DefaultConfig() Config {
+	return Config{
+		Host: "0.0.0.0", Port: 9910,
+		Service: ServiceConfig{ConnMax: 1024, Framing: config.DefaultFraming(), Limits: config.DefaultLimits()},
+	}
+}
+
+// LoadConfig load the defaults, then the file of path when it is not empty, then the environment
+// variables start with envPrefix when it is not empty, e.g. NETWORK_SERVICE_FRAMING_HEADER_LEN
+func LoadConfig(path, envPrefix string) (Config, error) {
+	cfg := DefaultConfig()
+	if path != "" {
+		if err := config.Load(path, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+	if envPrefix != "" {
+		if err := config.Env(envPrefix, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+
+	return cfg, cfg.Validate()
+}
+
+func (c Config) Validate() error {
+	if c.Port < 0 || c.Port > 65535 {
+		return config.Invalid("port", "must be in [0, 65535], %d given", c.Port)
+	}
+	if err := c.Service.Validate(); err != nil {
+		return config.Prefix("service", err)
+	}
+	if c.SessionGrace < 0 {
+		return config.Invalid("session_grace", "must not be negative, %s given", c.SessionGrace.Std())
+	}
+	if c.Outbox.Window < 0 {
+		return config.Invalid("outbox.window", "must not be negative, %s given", c.Outbox.Window.Std())
+	}
+	if c.Outbox.MaxPending < 0 {
+		return config.Invalid("outbox.max_pending", "must not be negative, %d given", c.Outbox.MaxPending)
+	}
+
+	return nil
+}
+
+func (c ServiceConfig) Validate() error {
+	if c.ConnMax <= 0 {
+		return config.Invalid("conn_max", "must be positive, %d given", c.ConnMax)
+	}
+	if err := c.Framing.Validate(); err != nil {
+		return config.Prefix("framing", err)
+	}
+	if err := c.Limits.Validate(c.Framing.HeaderLen); err != nil {
+		return config.Prefix("limits", err)
+	}
+	if err := c.Timeouts.Validate(); err != nil {
+		return config.Prefix("timeouts", err)
+	}
+	if c.MaxIdleTime < 0 {
+		return config.Invalid("max_idle_time", "must not be negative, %s given", c.MaxIdleTime.Std())
+	}
+	if err := c.Secure.Validate(); err != nil {
+		return config.Prefix("secure", err)
+	}
+	if err := c.TLS.Validate(true); err != nil {
+		return config.Prefix("tls", err)
+	}
+	if err := c.LogSampling.Validate(); err != nil {
+		return config.Prefix("log_sampling", err)
+	}
+	if err := c.Negotiation.Validate(); err != nil {
+		return config.Prefix("negotiation", err)
+	}
+
+	return nil
+}
+
+// NewTcpServiceFrom validate the config and create the TcpService of it
+func NewTcpServiceFrom(c ServiceConfig) (*TcpService, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := c.TLS.Server()
+	if err != nil {
+		return nil, err
+	}
+
+	t, _ := c.Framing.LenType()
+	e, _ := c.Framing.ByteOrder()
+	s := NewTcpService(c.ConnMax).WithHeaderLen(c.Framing.HeaderLen).WithBodyLenOffset(c.Framing.BodyLenOffset).WithBodyLenType(t).WithEndian(e)
+	s.WithMaxLen(c.Limits.MaxLen).WithReadBuffLen(c.Limits.ReadBuffLen).WithShrinkAfter(c.Limits.ShrinkAfter.Std()).WithZeroCopy(c.Limits.ZeroCopy)
+	s.WithReadTimeout(c.Timeouts.Read.Std()).WithWriteTimeout(c.Timeouts.Write.Std()).WithHandshakeTimeout(c.Timeouts.Handshake.Std())
+	s.WithMaxIdleTime(c.MaxIdleTime.Std()).WithTLS(tlsConfig).WithSchema(c.Framing.Schema())
+	s.WithKind(c.Framing.Kind.KindOffset())
+	if ct, _ := c.Framing.Checksum.ChecksumType(); ct != 0 {
+		action, _ := c.Framing.Checksum.ChecksumAction()
+		s.WithChecksum(ct, c.Framing.Checksum.Offset).WithChecksumAction(action, nil)
+	}
+	if suite, ok := c.Secure.Suite(); ok {
+		s.WithSecure(suite, c.Secure.RotateEvery).WithPSK([]byte(c.Secure.PSK))
+	}
+	if len(c.Negotiation.Versions) > 0 {
+		supported, required, _ := c.Negotiation.Flags()
+		s.WithVersions(c.Negotiation.Versions...).WithFeatures(supported, required)
+	}
+	if c.LogSampling.Burst > 0 {
+		s.WithLogSampling(c.LogSampling.Burst, c.LogSampling.Interval.Std())
+	}
+
+	return s, nil
+}
+
+// NewServerFrom validate the config and create the server with the TcpService of it,
+// the handler is set by WithHandler
+func NewServerFrom(c Config) (*Server, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	service, err := NewTcpServiceFrom(c.Service)
+	if err != nil {
+		return nil, err
+	}
+
+	s := NewServer(c.Host, c.Port).WithService(service)
+	if c.SessionGrace > 0 {
+		s.WithSessions(c.SessionGrace.Std())
+	}
+	if c.Outbox.Window > 0 {
+		s.WithOutbox(c.Outbox.Window.Std(), c.Outbox.MaxPending)
+	}
+
+	return s, nil
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kovey/network-go/v2/config"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.yaml")
+	yaml := `host: 127.0.0.1
+port: 9920
+session_grace: 30s
+service:
+  conn_max: 10
+  framing: {header_len: 6, body_len_offset: 2, body_len_type: uint16, endian: little, kind: {enable: true, offset: 0}}
+  limits: {max_len: 4096, zero_copy: 256}
+  timeouts: {read: 5s, write: 2s}
+  secure: {cipher: chacha20-poly1305}
+`
+	if err := os.WriteFile(path, []byte(yaml), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TSRV_SERVICE_LIMITS_READ_BUFF_LEN", "512")
+	t.Setenv("TSRV_SERVICE_TIMEOUTS_HANDSHAKE", "3s")
+	t.Setenv("TSRV_PORT", "9921")
+
+	c, err := LoadConfig(path, "tsrv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the env overrides the file, the file overrides the defaults
+	if c.Port != 9921 || c.Service.Limits.ReadBuffLen != 512 || c.Service.Timeouts.Handshake.Std() != 3*time.Second ||
+		c.Service.Timeouts.Read.Std() != 5*time.Second || c.Service.Limits.ShrinkAfter.Std() != 30*time.Second {
+		t.Fatalf("%+v", c)
+	}
+
+	s, err := NewServerFrom(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings := s.Config()
+	if settings["port"] != 9921 || settings["read_buff_len"] != 512 || settings["zero_copy"] != 256 ||
+		settings["handshake_timeout"] != "3s" || settings["session_grace"] != "30s" || settings["cipher"] == nil {
+		t.Fatal(settings)
+	}
+
+	t.Setenv("TSRV_PORT", "abc")
+	if _, err := LoadConfig(path, "tsrv"); err == nil || !strings.Contains(err.Error(), "TSRV_PORT") {
+		t.Fatal(err)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(c *Config)
+		field string
+	}{
+		{"port", func(c *Config) { c.Port = 70000 }, "port"},
+		{"conn max", func(c *Config) { c.Service.ConnMax = 0 }, "service.conn_max"},
+		{"body len offset", func(c *Config) { c.Service.Framing.BodyLenOffset = 2 }, "service.framing.body_len_offset"},
+		{"body len type", func(c *Config) { c.Service.Framing.BodyLenType = "int24" }, "service.framing.body_len_type"},
+		{"max len", func(c *Config) { c.Service.Limits.MaxLen = 4 }, "service.limits.max_len"},
+		{"session grace", func(c *Config) { c.SessionGrace = config.Duration(-time.Second) }, "session_grace"},
+		{"outbox", func(c *Config) { c.Outbox.MaxPending = -1 }, "outbox.max_pending"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := DefaultConfig()
+			tt.setup(&c)
+			err := c.Validate()
+			if !errors.Is(err, config.Err_Invalid) || !strings.Contains(err.Error(), ": "+tt.field+": ") {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/kovey/network-go/v2/connection"
+	"github.com/kovey/network-go/v2/logger"
 	"github.com/kovey/pool"
 	"github.com/kovey/pool/object"
 )
@@ -42,3 +43,12 @@ func (c *Context) Reset() {
 	c.SpanId = ""
 	c.Context = nil
 }
+
+// Logger the logger of the connection with the trace id
+func (c *Context) Logger() logger.ILogger {
+	if c.TraceId == "" {
+		return c.Conn.Logger()
+	}
+
+	return c.Conn.Logger().With(logger.Trace(c.TraceId))
+}
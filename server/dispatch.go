@@ -0,0 +1,168 @@
+package server
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/kovey/debug-go/run"
+	"github.com/kovey/network-go/v2/connection"
+)
+
+var Err_Dispatch_Dropped = errors.New("dispatch queue is full, packet dropped")
+var Err_Dispatch_Overload = errors.New("dispatch queue is full")
+var Err_Dispatch_Shutdown = errors.New("dispatcher is shutdown")
+
+type OverloadPolicy byte
+
+const (
+	Overload_Block OverloadPolicy = 1 // wait until the queue has space
+	Overload_Drop  OverloadPolicy = 2 // drop the packet
+	Overload_Close OverloadPolicy = 3 // close the connection
+)
+
+type IDispatcher interface {
+	Dispatch(conn *connection.Connection, packet *connection.Packet, task func()) error
+	Shutdown()
+}
+
+// SequentialDispatcher run the task inline in the goroutine of the connection
+type SequentialDispatcher struct {
+}
+
+func NewSequentialDispatcher() *SequentialDispatcher {
+	return &SequentialDispatcher{}
+}
+
+func (d *SequentialDispatcher) Dispatch(conn *connection.Connection, packet *connection.Packet, task func()) error {
+	task()
+	return nil
+}
+
+func (d *SequentialDispatcher) Shutdown() {
+}
+
+type worker struct {
+	tasks chan func()
+}
+
+func (w *worker) run(wait *sync.WaitGroup) {
+	defer wait.Done()
+	for task := range w.tasks {
+		w.exec(task)
+	}
+}
+
+func (w *worker) exec(task func()) {
+	defer func() {
+		run.Panic(recover())
+	}()
+	task()
+}
+
+func (w *worker) push(task func(), policy OverloadPolicy) error {
+	if policy == Overload_Block {
+		w.tasks <- task
+		return nil
+	}
+
+	select {
+	case w.tasks <- task:
+		return nil
+	default:
+		if policy == Overload_Close {
+			return Err_Dispatch_Overload
+		}
+		return Err_Dispatch_Dropped
+	}
+}
+
+type workers struct {
+	workers    []*worker
+	policy     OverloadPolicy
+	wait       sync.WaitGroup
+	locker     sync.RWMutex
+	isShutdown bool
+}
+
+// start count workers, at least one, the queue size less than 0 is 0
+func (w *workers) start(count, queueSize int, shared bool) {
+	count = max(count, 1)
+	queueSize = max(queueSize, 0)
+	var tasks chan func()
+	for i := 0; i < count; i++ {
+		if !shared || tasks == nil {
+			tasks = make(chan func(), queueSize)
+		}
+		w.workers = append(w.workers, &worker{tasks: tasks})
+		w.wait.Add(1)
+		go w.workers[i].run(&w.wait)
+	}
+}
+
+func (w *workers) push(index uint64, task func()) error {
+	w.locker.RLock()
+	defer w.locker.RUnlock()
+	if w.isShutdown {
+		return Err_Dispatch_Shutdown
+	}
+
+	return w.workers[index%uint64(len(w.workers))].push(task, w.policy)
+}
+
+func (w *workers) Shutdown() {
+	w.locker.Lock()
+	if w.isShutdown {
+		w.locker.Unlock()
+		return
+	}
+	w.isShutdown = true
+	closed := make(map[chan func()]bool)
+	for _, wk := range w.workers {
+		if !closed[wk.tasks] {
+			closed[wk.tasks] = true
+			close(wk.tasks)
+		}
+	}
+	w.locker.Unlock()
+	w.wait.Wait()
+}
+
+// PoolDispatcher run the tasks of all connections on a bounded shared worker pool,
+// packets of the same connection may be handled out of order
+type PoolDispatcher struct {
+	workers
+}
+
+// NewPoolDispatcher count less than 1 is 1, queueSize less than 0 is 0
+func NewPoolDispatcher(count, queueSize int, policy OverloadPolicy) *PoolDispatcher {
+	d := &PoolDispatcher{workers: workers{policy: policy}}
+	d.start(count, queueSize, true)
+	return d
+}
+
+func (d *PoolDispatcher) Dispatch(conn *connection.Connection, packet *connection.Packet, task func()) error {
+	return d.push(0, task)
+}
+
+// KeyedDispatcher hash the key to one worker, packets with the same key are handled in order
+type KeyedDispatcher struct {
+	workers
+	key func(*connection.Connection, *connection.Packet) uint64
+}
+
+// NewKeyedDispatcher key is the fd of the connection when key is nil, count less than 1 is 1, queueSize less than 0 is 0
+func NewKeyedDispatcher(count, queueSize int, policy OverloadPolicy, key func(*connection.Connection, *connection.Packet) uint64) *KeyedDispatcher {
+	if key == nil {
+		key = func(conn *connection.Connection, _ *connection.Packet) uint64 {
+			return conn.FD()
+		}
+	}
+
+	d := &KeyedDispatcher{workers: workers{policy: policy}, key: key}
+	d.start(count, queueSize, false)
+	return d
+}
+
+func (d *KeyedDispatcher) Dispatch(conn *connection.Connection, packet *connection.Packet, task func()) error {
+	return d.push(d.key(conn, packet), task)
+}
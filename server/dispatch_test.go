@@ -0,0 +1,35 @@
+package server
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/kovey/network-go/v2/connection"
+)
+
+func TestDispatcherCount(t *testing.T) {
+	tests := []struct {
+		name       string
+		dispatcher IDispatcher
+	}{
+		{"pool zero", NewPoolDispatcher(0, 1, Overload_Block)},
+		{"pool negative", NewPoolDispatcher(-1, -1, Overload_Block)},
+		{"keyed zero", NewKeyedDispatcher(0, 1, Overload_Block, nil)},
+		{"keyed negative", NewKeyedDispatcher(-2, -1, Overload_Block, nil)},
+	}
+
+	conn := connection.NewConnection(7, nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done := make(chan struct{})
+			if err := tt.dispatcher.Dispatch(conn, nil, func() { close(done) }); err != nil {
+				t.Fatal(err)
+			}
+			<-done
+			tt.dispatcher.Shutdown()
+			if err := tt.dispatcher.Dispatch(conn, nil, func() {}); !errors.Is(err, Err_Dispatch_Shutdown) {
+				t.Fatal(err)
+			}
+		})
+	}
+}
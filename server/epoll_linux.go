@@ -0,0 +1,279 @@
+//go:build linux
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/kovey/debug-go/run"
+	"github.com/kovey/network-go/v2/connection"
+	"github.com/kovey/network-go/v2/logger"
+)
+
+const (
+	epoll_events    = 128
+	epoll_read_size = 64 * 1024
+	epoll_wait_ms   = 1000
+)
+
+// EpollService serve the connections with a few epoll event loops,
+// the read buffer of a connection is allocated only when data arrives.
+// Listen and Serve fail with Err_Event_Unsupported when secure handshake, version negotiation, tls or
+// the timeouts are set, the handler runs in the event loop unless a dispatcher is set to the server
+type EpollService struct {
+	*TcpService
+	loops []*eventLoop
+}
+
+func NewEpollService(connMax, loops int) *EpollService {
+	if loops <= 0 {
+		loops = 1
+	}
+
+	return &EpollService{TcpService: NewTcpService(connMax), loops: make([]*eventLoop, loops)}
+}
+
+// validate reject the settings the event loops do not apply
+func (e *EpollService) validate() error {
+	settings := []struct {
+		name  string
+		isSet bool
+	}{
+		{"secure channel", e.cipher != 0}, {"version negotiation", len(e.versions) > 0}, {"tls", e.tls != nil},
+		{"read timeout", e.readTimeout > 0}, {"write timeout", e.writeTimeout > 0}, {"handshake timeout", e.handshakeTimeout > 0},
+	}
+	for _, setting := range settings {
+		if setting.isSet {
+			return fmt.Errorf("%w: %s", Err_Event_Unsupported, setting.name)
+		}
+	}
+
+	return nil
+}
+
+func (e *EpollService) Listen(host string, port int) error {
+	if err := e.validate(); err != nil {
+		return err
+	}
+
+	return e.TcpService.Listen(host, port)
+}
+
+func (e *EpollService) Serve(event IEvent) error {
+	if err := e.validate(); err != nil {
+		return err
+	}
+
+	for i := range e.loops {
+		loop, err := newEventLoop(event, e.log())
+		if err != nil {
+			return err
+		}
+
+		e.loops[i] = loop
+		go loop.run()
+	}
+
+	for {
+		if e.IsClosed() {
+			break
+		}
+
+		raw, conn, err := e.accept()
+		if err != nil {
+			if e.IsClosed() {
+				break
+			}
+
+			e.log().Erro("accept failure", logger.Err(err))
+			continue
+		}
+
+		if err := event.OnOpen(conn); err != nil {
+			conn.Logger().Erro("open failure", logger.Err(err))
+			conn.Close()
+			e.Close()
+			continue
+		}
+
+		if err := e.loops[conn.FD()%uint64(len(e.loops))].add(raw, conn); err != nil {
+			conn.Logger().Erro("add to event loop failure", logger.Err(err))
+			event.OnClose(conn, err)
+		}
+	}
+
+	for _, loop := range e.loops {
+		if loop != nil {
+			loop.shutdown()
+		}
+	}
+
+	return nil
+}
+
+type eventLoop struct {
+	epfd     int
+	event    IEvent
+	conns    map[int]*connection.Connection
+	locker   sync.Mutex
+	buff     []byte
+	isClosed atomic.Bool
+	done     chan struct{}
+	logger   logger.ILogger
+}
+
+func newEventLoop(event IEvent, l logger.ILogger) (*eventLoop, error) {
+	epfd, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+
+	return &eventLoop{epfd: epfd, event: event, logger: l, conns: make(map[int]*connection.Connection), buff: make([]byte, epoll_read_size), done: make(chan struct{})}, nil
+}
+
+func rawFD(conn net.Conn) (int, error) {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return 0, errors.New("connection is not implements syscall.Conn")
+	}
+
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+
+	fd := -1
+	if err := raw.Control(func(f uintptr) { fd = int(f) }); err != nil {
+		return 0, err
+	}
+
+	return fd, nil
+}
+
+func (l *eventLoop) add(raw net.Conn, conn *connection.Connection) error {
+	fd, err := rawFD(raw)
+	if err != nil {
+		return err
+	}
+
+	l.locker.Lock()
+	l.conns[fd] = conn
+	l.locker.Unlock()
+	conn.OnClose(func(*connection.Connection) {
+		l.remove(fd, conn)
+	})
+
+	return syscall.EpollCtl(l.epfd, syscall.EPOLL_CTL_ADD, fd, &syscall.EpollEvent{Events: syscall.EPOLLIN | syscall.EPOLLRDHUP, Fd: int32(fd)})
+}
+
+func (l *eventLoop) remove(fd int, conn *connection.Connection) {
+	l.locker.Lock()
+	defer l.locker.Unlock()
+	if l.conns[fd] != conn {
+		return
+	}
+
+	delete(l.conns, fd)
+	syscall.EpollCtl(l.epfd, syscall.EPOLL_CTL_DEL, fd, nil)
+}
+
+func (l *eventLoop) get(fd int) *connection.Connection {
+	l.locker.Lock()
+	defer l.locker.Unlock()
+	return l.conns[fd]
+}
+
+func (l *eventLoop) run() {
+	defer close(l.done)
+	defer func() {
+		run.Panic(recover())
+	}()
+
+	events := make([]syscall.EpollEvent, epoll_events)
+	lastCheck := time.Now()
+	for !l.isClosed.Load() {
+		n, err := syscall.EpollWait(l.epfd, events, epoll_wait_ms)
+		if err != nil && err != syscall.EINTR {
+			l.logger.Erro("epoll wait failure", logger.Err(err))
+			return
+		}
+
+		for i := 0; i < n; i++ {
+			l.handle(int(events[i].Fd), events[i].Events)
+		}
+
+		if now := time.Now(); now.Sub(lastCheck) >= time.Second {
+			lastCheck = now
+			l.expire(now)
+		}
+	}
+}
+
+func (l *eventLoop) handle(fd int, events uint32) {
+	conn := l.get(fd)
+	if conn == nil {
+		return
+	}
+
+	if events&syscall.EPOLLIN != 0 {
+		n, err := syscall.Read(fd, l.buff)
+		if err == syscall.EAGAIN || err == syscall.EINTR {
+			return
+		}
+		if err != nil {
+			l.event.OnClose(conn, connection.NewError(connection.Err_IO, err))
+			return
+		}
+		if n == 0 {
+			l.event.OnClose(conn, connection.NewError(connection.Err_EOF, io.EOF))
+			return
+		}
+
+		packets, err := conn.Feed(l.buff[:n])
+		for _, packet := range packets {
+			if e := l.event.OnPacket(conn, packet); e != nil && e != Err_Dispatch_Dropped {
+				conn.Logger().Erro("dispatch failure", logger.Err(e))
+				l.event.OnClose(conn, e)
+				return
+			}
+		}
+
+		if err != nil {
+			conn.Logger().Erro("read data failure", logger.Err(err))
+			l.event.OnClose(conn, err)
+		}
+		return
+	}
+
+	if events&(syscall.EPOLLHUP|syscall.EPOLLERR|syscall.EPOLLRDHUP) != 0 {
+		l.event.OnClose(conn, connection.Err_EOF)
+	}
+}
+
+func (l *eventLoop) expire(now time.Time) {
+	var expired []*connection.Connection
+	l.locker.Lock()
+	for _, conn := range l.conns {
+		if conn.Expired(now) {
+			expired = append(expired, conn)
+		}
+	}
+	l.locker.Unlock()
+
+	for _, conn := range expired {
+		l.event.OnClose(conn, connection.Err_Idle_Timeout)
+	}
+}
+
+func (l *eventLoop) shutdown() {
+	l.isClosed.Store(true)
+	<-l.done
+	syscall.Close(l.epfd)
+}
@@ -0,0 +1,112 @@
+//go:build linux
+
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/kovey/network-go/v2/connection"
+)
+
+type echoHandler struct{ got chan string }
+
+func (h *echoHandler) Connect(*connection.Connection) error { return nil }
+func (h *echoHandler) Receive(ctx *Context) error {
+	h.got <- string(ctx.Data.Body)
+	return ctx.Conn.Write(ctx.Data.Bytes())
+}
+func (h *echoHandler) Close(*connection.Connection) error { h.got <- "closed"; return nil }
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func TestEpollFeed(t *testing.T) {
+	h := &echoHandler{got: make(chan string, 10)}
+	port := freePort(t)
+	s := NewServer("127.0.0.1", port).WithHandler(h).WithService(NewEpollService(100, 2))
+	serveTest(t, s)
+
+	c, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := connection.NewPacket([]byte("hi"), connection.NewHeader()).Bytes()
+	c.Write(append(p, p[:3]...))
+	time.Sleep(50 * time.Millisecond)
+	c.Write(p[3:])
+	for i := 0; i < 2; i++ {
+		if g := <-h.got; g != "hi" {
+			t.Fatal(g)
+		}
+	}
+
+	buf := make([]byte, 2*len(p))
+	c.SetReadDeadline(time.Now().Add(time.Second))
+	if n, err := c.Read(buf); n < len(p) {
+		t.Fatal(n, err)
+	}
+	c.Close()
+	if g := <-h.got; g != "closed" {
+		t.Fatal(g)
+	}
+}
+
+func TestEpollUnsupported(t *testing.T) {
+	setups := map[string]func(e *EpollService){
+		"secure channel":      func(e *EpollService) { e.WithSecure(connection.Cipher_AES_128_GCM, 0) },
+		"version negotiation": func(e *EpollService) { e.WithVersions(1) },
+		"tls":                 func(e *EpollService) { e.WithTLS(&tls.Config{}) },
+		"read timeout":        func(e *EpollService) { e.WithReadTimeout(time.Second) },
+		"write timeout":       func(e *EpollService) { e.WithWriteTimeout(time.Second) },
+		"handshake timeout":   func(e *EpollService) { e.WithHandshakeTimeout(time.Second) },
+	}
+	for name, setup := range setups {
+		e := NewEpollService(10, 1)
+		setup(e)
+		err := NewServer("127.0.0.1", 0).WithService(e).WithHandler(&countHandler{}).ServeContext(context.Background())
+		if !errors.Is(err, Err_Event_Unsupported) {
+			t.Fatal(name, err)
+		}
+		if err := e.Serve(nil); !errors.Is(err, Err_Event_Unsupported) {
+			t.Fatal(name, err)
+		}
+	}
+
+	s := NewServer("127.0.0.1", 0).WithService(NewEpollService(10, 1)).WithHandler(&countHandler{}).WithAuthenticator(tokenAuth{}, 0)
+	if err := s.ServeContext(context.Background()); !errors.Is(err, Err_Event_Unsupported) {
+		t.Fatal(err)
+	}
+}
+
+func TestEpollKick(t *testing.T) {
+	h := &countHandler{}
+	port := freePort(t)
+	s := NewServer("127.0.0.1", port).WithHandler(h).WithService(NewEpollService(100, 1))
+	serveTest(t, s)
+
+	c, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	waitFor(t, func() bool { return h.connects.Load() == 1 })
+
+	s.Kick(s.Conns()[0])
+	waitFor(t, func() bool { return h.closes.Load() == 1 })
+	if len(s.Conns()) != 0 {
+		t.Fatal(len(s.Conns()))
+	}
+}
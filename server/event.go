@@ -0,0 +1,54 @@
+package server
+
+import (
+	"errors"
+
+	"github.com/kovey/network-go/v2/connection"
+	"github.com/kovey/network-go/v2/logger"
+)
+
+var Err_Event_Unsupported = errors.New("not supported by the event service")
+
+// IEventService feed the complete packets of all connections through a few event loops
+// instead of running goroutines for every connection
+type IEventService interface {
+	IService
+	Serve(IEvent) error
+}
+
+type IEvent interface {
+	OnOpen(*connection.Connection) error
+	OnPacket(*connection.Connection, *connection.Packet) error
+	// OnClose close the connection for reason
+	OnClose(conn *connection.Connection, reason error)
+}
+
+type events struct {
+	s *Server
+}
+
+func (e *events) OnOpen(conn *connection.Connection) error {
+	if e.s.IsMaintain() {
+		return Err_Maintain
+	}
+
+	conn.WithContext(e.s.ctx)
+	e.s.conns.Store(conn.FD(), conn)
+	// the connection closed directly, such as kicked, is removed and told to the handler
+	conn.OnClose(func(c *connection.Connection) {
+		e.s.Close(c.FD())
+	})
+	e.s.open(conn)
+	e.s.connect(conn)
+	return nil
+}
+
+func (e *events) OnPacket(conn *connection.Connection, packet *connection.Packet) error {
+	return e.s.dispatch(packet, conn)
+}
+
+func (e *events) OnClose(conn *connection.Connection, reason error) {
+	if err := e.s.CloseWith(conn.FD(), reason); err != nil {
+		conn.Logger().Erro("close failure", logger.Err(err))
+	}
+}
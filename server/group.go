@@ -0,0 +1,101 @@
+package server
+
+import (
+	"sync"
+
+	"github.com/kovey/network-go/v2/connection"
+)
+
+const group_key = "ko.network.groups"
+
+// groups the names joined by a connection are kept in its attributes, guarded by locker
+type groups struct {
+	members map[string]map[uint64]*connection.Connection
+	locker  sync.RWMutex
+}
+
+// Join add the connection to the group, the connection leaves all groups when it is closed
+func (s *Server) Join(group string, conn *connection.Connection) {
+	s.groups.locker.Lock()
+	defer s.groups.locker.Unlock()
+	if s.groups.members == nil {
+		s.groups.members = make(map[string]map[uint64]*connection.Connection)
+	}
+	members, ok := s.groups.members[group]
+	if !ok {
+		members = make(map[uint64]*connection.Connection)
+		s.groups.members[group] = members
+	}
+	members[conn.FD()] = conn
+
+	names, ok := connection.Attr[map[string]bool](conn, group_key)
+	if !ok {
+		names = make(map[string]bool)
+		conn.Set(group_key, names)
+		conn.OnClose(func(c *connection.Connection) {
+			s.leaveAll(c)
+		})
+	}
+	names[group] = true
+}
+
+func (s *Server) Leave(group string, conn *connection.Connection) {
+	s.groups.locker.Lock()
+	defer s.groups.locker.Unlock()
+	s.leave(group, conn)
+	if names, ok := connection.Attr[map[string]bool](conn, group_key); ok {
+		delete(names, group)
+	}
+}
+
+func (s *Server) leave(group string, conn *connection.Connection) {
+	members, ok := s.groups.members[group]
+	if !ok || members[conn.FD()] != conn {
+		return
+	}
+
+	delete(members, conn.FD())
+	if len(members) == 0 {
+		delete(s.groups.members, group)
+	}
+}
+
+// leaveAll remove the closed connection from the groups, the names are kept for session resumption
+func (s *Server) leaveAll(conn *connection.Connection) {
+	s.groups.locker.Lock()
+	defer s.groups.locker.Unlock()
+	names, _ := connection.Attr[map[string]bool](conn, group_key)
+	for group := range names {
+		s.leave(group, conn)
+	}
+}
+
+// GroupsOf the names of the groups the connection joined
+func (s *Server) GroupsOf(conn *connection.Connection) []string {
+	s.groups.locker.RLock()
+	defer s.groups.locker.RUnlock()
+	names, _ := connection.Attr[map[string]bool](conn, group_key)
+	groups := make([]string, 0, len(names))
+	for name := range names {
+		groups = append(groups, name)
+	}
+	return groups
+}
+
+func (s *Server) Members(group string) []*connection.Connection {
+	s.groups.locker.RLock()
+	defer s.groups.locker.RUnlock()
+	members := s.groups.members[group]
+	conns := make([]*connection.Connection, 0, len(members))
+	for _, conn := range members {
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+// Broadcast write pack to all connections of the group
+func (s *Server) Broadcast(group string, pack []byte) {
+	for _, conn := range s.Members(group) {
+		conn.Write(pack)
+	}
+}
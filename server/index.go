@@ -0,0 +1,82 @@
+package server
+
+import (
+	"github.com/kovey/network-go/v2/connection"
+	"github.com/kovey/network-go/v2/logger"
+)
+
+const bind_key = "ko.network.bind"
+
+// WithKick build the packet sent to the previous connection before it is kicked by Bind
+func (s *Server) WithKick(kick func(*connection.Connection) []byte) *Server {
+	s.kick = kick
+	return s
+}
+
+// Bind index the connection by key, such as user id,
+// the previous connection bound to the same key is kicked
+func (s *Server) Bind(key any, conn *connection.Connection) {
+	if old, ok := s.index.Swap(key, conn); ok {
+		if prev, sure := old.(*connection.Connection); sure && prev != conn {
+			prev.Delete(bind_key)
+			s.Kick(prev)
+		}
+	}
+
+	old, bound := connection.Attr[any](conn, bind_key)
+	if bound && old != key {
+		s.index.CompareAndDelete(old, conn)
+	}
+
+	conn.Set(bind_key, key)
+	s.redeliver(key, conn)
+	if bound {
+		return
+	}
+
+	conn.OnClose(func(c *connection.Connection) {
+		if key, ok := connection.Attr[any](c, bind_key); ok {
+			s.index.CompareAndDelete(key, c)
+		}
+	})
+}
+
+func (s *Server) Unbind(key any) {
+	if old, ok := s.index.LoadAndDelete(key); ok {
+		if conn, sure := old.(*connection.Connection); sure {
+			conn.Delete(bind_key)
+		}
+	}
+}
+
+func (s *Server) Lookup(key any) (*connection.Connection, bool) {
+	conn, ok := s.index.Load(key)
+	if !ok {
+		return nil, false
+	}
+
+	c, ok := conn.(*connection.Connection)
+	return c, ok
+}
+
+// Kick send the kick packet, close the connection and remove it from the index,
+// the connection not admitted yet or served by an IEventService is closed too
+func (s *Server) Kick(conn *connection.Connection) error {
+	if s.kick != nil {
+		if pack := s.kick(conn); pack != nil {
+			if err := conn.Write(pack); err != nil {
+				conn.Logger().Erro("write kick packet failure", logger.Err(err))
+			}
+		}
+	}
+
+	err := conn.CloseWith(connection.Err_Kicked)
+	if key, ok := connection.Attr[any](conn, bind_key); ok {
+		s.index.CompareAndDelete(key, conn)
+	}
+	if err == connection.Err_Closed {
+		return nil
+	}
+
+	return err
+}
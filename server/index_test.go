@@ -0,0 +1,85 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/kovey/network-go/v2/connection"
+)
+
+func TestBindKick(t *testing.T) {
+	h := &countHandler{}
+	s := NewServer("auth", 3).WithService(NewMemoryService(10)).WithHandler(h).WithKick(func(*connection.Connection) []byte {
+		return connection.NewPacket([]byte("kicked"), connection.NewHeader()).Bytes()
+	})
+	serveTest(t, s)
+
+	first := dialMemory(t, 3)
+	waitFor(t, func() bool { return h.connects.Load() == 1 })
+	prev := s.Conns()[0]
+	second := dialMemory(t, 3)
+	waitFor(t, func() bool { return h.connects.Load() == 2 })
+	var next *connection.Connection
+	for _, conn := range s.Conns() {
+		if conn != prev {
+			next = conn
+		}
+	}
+
+	s.Bind("user", prev)
+	// the kick packet is written while the client reads
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Bind("user", next)
+	}()
+	if p, err := first.Read(); err != nil || string(p.Body) != "kicked" {
+		t.Fatal(p, err)
+	}
+	<-done
+	if _, err := first.Read(); !errors.Is(err, io.EOF) {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return h.closes.Load() == 1 })
+	if conn, ok := s.Lookup("user"); !ok || conn != next {
+		t.Fatal(conn, ok)
+	}
+	if !errors.Is(prev.CloseReason(), connection.Err_Kicked) || len(s.Conns()) != 1 {
+		t.Fatal(prev.CloseReason(), len(s.Conns()))
+	}
+
+	go second.Read()
+	s.Kick(next)
+	if _, ok := s.Lookup("user"); ok {
+		t.Fatal("kicked connection is still bound")
+	}
+	waitFor(t, func() bool { return h.closes.Load() == 2 })
+}
+
+func TestKickPending(t *testing.T) {
+	h := &countHandler{}
+	s := NewServer("auth", 4).WithService(NewMemoryService(10)).WithHandler(h).WithAuthenticator(tokenAuth{}, time.Second)
+	serveTest(t, s)
+
+	client := dialMemory(t, 4)
+	var pending *connection.Connection
+	waitFor(t, func() bool {
+		s.pending.Range(func(_, conn any) bool {
+			pending = conn.(*connection.Connection)
+			return false
+		})
+		return pending != nil
+	})
+
+	if err := s.Kick(pending); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Read(); !errors.Is(err, io.EOF) {
+		t.Fatal(err)
+	}
+	if h.connects.Load() != 0 || h.closes.Load() != 0 {
+		t.Fatal(h.connects.Load(), h.closes.Load())
+	}
+}
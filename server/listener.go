@@ -0,0 +1,371 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kovey/debug-go/run"
+	"github.com/kovey/network-go/v2/logger"
+)
+
+var Err_Listener_Closed = fmt.Errorf("mux listener: %w", net.ErrClosed)
+var Err_Not_Matched = errors.New("no matcher for the connection")
+
+// Match is the result of a matcher on the first bytes of a connection
+type Match byte
+
+const (
+	Match_No   Match = 0
+	Match_Yes  Match = 1
+	Match_More Match = 2 // more bytes are needed to decide
+)
+
+// Matcher classify the protocol by the first bytes of a connection, head is empty when the client sends nothing in time
+type Matcher func(head []byte) Match
+
+var httpMethods = [][]byte{
+	[]byte("GET "), []byte("POST "), []byte("HEAD "), []byte("PUT "), []byte("DELETE "),
+	[]byte("OPTIONS "), []byte("PATCH "), []byte("CONNECT "), []byte("TRACE "),
+}
+
+// MatchAny match every connection, it is registered last for the raw framed connections
+func MatchAny() Matcher {
+	return func([]byte) Match {
+		return Match_Yes
+	}
+}
+
+// MatchPrefix match the connections start with one of prefixes
+func MatchPrefix(prefixes ...[]byte) Matcher {
+	return func(head []byte) Match {
+		result := Match_No
+		for _, prefix := range prefixes {
+			if bytes.HasPrefix(head, prefix) {
+				return Match_Yes
+			}
+			if len(head) < len(prefix) && bytes.HasPrefix(prefix, head) {
+				result = Match_More
+			}
+		}
+
+		return result
+	}
+}
+
+// MatchHTTP match the http/1 requests
+func MatchHTTP() Matcher {
+	return MatchPrefix(httpMethods...)
+}
+
+// MatchWebSocket match the http/1 requests upgrade to websocket, it is registered before MatchHTTP
+func MatchWebSocket() Matcher {
+	get := MatchPrefix([]byte("GET "))
+	return func(head []byte) Match {
+		if m := get(head); m != Match_Yes {
+			return m
+		}
+
+		end := bytes.Index(head, []byte("\r\n\r\n"))
+		if end < 0 {
+			return Match_More
+		}
+
+		for _, line := range bytes.Split(head[:end], []byte("\r\n"))[1:] {
+			name, value, ok := bytes.Cut(line, []byte(":"))
+			if ok && bytes.EqualFold(bytes.TrimSpace(name), []byte("Upgrade")) && bytes.EqualFold(bytes.TrimSpace(value), []byte("websocket")) {
+				return Match_Yes
+			}
+		}
+
+		return Match_No
+	}
+}
+
+// MatchTLS match the tls client hello
+func MatchTLS() Matcher {
+	return MatchPrefix([]byte{0x16, 0x03})
+}
+
+// MuxListener accept the connections on one port and route them to the virtual listeners by the first bytes,
+// the matchers are tried in the order they are registered and the first matched wins
+type MuxListener struct {
+	listen      func(address string) (net.Listener, error)
+	root        net.Listener
+	routes      []*virtualListener
+	servers     []*http.Server
+	peekTimeout time.Duration
+	maxPeek     int
+	logger      logger.ILogger
+	wait        sync.WaitGroup
+	closeOnce   sync.Once
+	done        chan struct{}
+}
+
+func NewMuxListener() *MuxListener {
+	return &MuxListener{peekTimeout: 3 * time.Second, maxPeek: 4096, done: make(chan struct{})}
+}
+
+// WithPeekTimeout the time to wait for the first bytes, the matchers get the bytes read until then
+func (m *MuxListener) WithPeekTimeout(timeout time.Duration) *MuxListener {
+	m.peekTimeout = timeout
+	return m
+}
+
+// WithMaxPeek the max bytes read to classify a connection
+func (m *MuxListener) WithMaxPeek(length int) *MuxListener {
+	m.maxPeek = length
+	return m
+}
+
+func (m *MuxListener) WithLogger(l logger.ILogger) *MuxListener {
+	m.logger = l
+	return m
+}
+
+// WithListen the function listens on the address, e.g. memnet.Listen in tests
+func (m *MuxListener) WithListen(listen func(address string) (net.Listener, error)) *MuxListener {
+	m.listen = listen
+	return m
+}
+
+func (m *MuxListener) log() logger.ILogger {
+	return logger.Or(m.logger)
+}
+
+// Match the virtual listener of the connections matched, it must be called before Listen
+func (m *MuxListener) Match(matcher Matcher) net.Listener {
+	l := &virtualListener{m: m, matcher: matcher, conns: make(chan net.Conn), done: make(chan struct{})}
+	m.routes = append(m.routes, l)
+	return l
+}
+
+// Service the TcpService serves the connections matched
+func (m *MuxListener) Service(matcher Matcher, connMax int) *TcpService {
+	return NewTcpService(connMax).WithListener(m.Match(matcher))
+}
+
+// HandleHTTP serve the connections matched with handler after Listen, e.g. the health check or websocket upgrade
+func (m *MuxListener) HandleHTTP(matcher Matcher, handler http.Handler) *MuxListener {
+	l := m.Match(matcher)
+	srv := &http.Server{Handler: handler, ReadHeaderTimeout: m.peekTimeout}
+	m.servers = append(m.servers, srv)
+	m.wait.Add(1)
+	go func() {
+		defer m.wait.Done()
+		if err := srv.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) && !errors.Is(err, net.ErrClosed) {
+			m.log().Erro("http serve failure", logger.Err(err))
+		}
+	}()
+	return m
+}
+
+// Listen on host:port and route the accepted connections until Close
+func (m *MuxListener) Listen(host string, port int) error {
+	listen := m.listen
+	if listen == nil {
+		listen = func(address string) (net.Listener, error) {
+			return net.Listen("tcp", address)
+		}
+	}
+
+	root, err := listen(fmt.Sprintf("%s:%d", host, port))
+	if err != nil {
+		return err
+	}
+
+	m.root = root
+	m.log().Info("mux listen", logger.F("host", host), logger.F("port", port), logger.F("routes", len(m.routes)))
+	m.wait.Add(1)
+	go m.loop()
+	return nil
+}
+
+func (m *MuxListener) loop() {
+	defer m.wait.Done()
+	for {
+		conn, err := m.root.Accept()
+		if err != nil {
+			select {
+			case <-m.done:
+				return
+			default:
+			}
+
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			m.log().Erro("mux accept failure", logger.Err(err))
+			continue
+		}
+
+		m.wait.Add(1)
+		go m.route(conn)
+	}
+}
+
+// route peek the first bytes of conn and send it to the matched virtual listener
+func (m *MuxListener) route(conn net.Conn) {
+	defer m.wait.Done()
+	defer func() {
+		run.Panic(recover())
+	}()
+
+	l, head, err := m.classify(conn)
+	if err != nil {
+		m.log().Warn("mux route failure", logger.Remote(conn.RemoteAddr()), logger.Err(err))
+		conn.Close()
+		return
+	}
+
+	pc := &peekedConn{Conn: conn, head: head}
+	select {
+	case l.conns <- pc:
+	case <-l.done:
+		conn.Close()
+	case <-m.done:
+		conn.Close()
+	}
+}
+
+func (m *MuxListener) classify(conn net.Conn) (*virtualListener, []byte, error) {
+	if m.peekTimeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(m.peekTimeout))
+		defer conn.SetReadDeadline(time.Time{})
+	}
+
+	head := make([]byte, 0, 512)
+	for {
+		l, more := m.match(head, false)
+		if l != nil {
+			return l, head, nil
+		}
+		if !more || len(head) >= m.maxPeek {
+			break
+		}
+
+		if len(head) == cap(head) {
+			head = append(head, 0)[:len(head)]
+		}
+		end := min(cap(head), m.maxPeek)
+		n, err := conn.Read(head[len(head):end])
+		head = head[:len(head)+n]
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				break
+			}
+			return nil, nil, err
+		}
+	}
+
+	if l, _ := m.match(head, true); l != nil {
+		return l, head, nil
+	}
+
+	return nil, nil, fmt.Errorf("%w: % x", Err_Not_Matched, head[:min(len(head), 16)])
+}
+
+// match the first listener matched while the listeners before it do not match,
+// more is true when a matcher needs more bytes, it is treated as not matched when final
+func (m *MuxListener) match(head []byte, final bool) (l *virtualListener, more bool) {
+	for _, route := range m.routes {
+		switch route.matcher(head) {
+		case Match_Yes:
+			if more {
+				return nil, true
+			}
+			return route, false
+		case Match_More:
+			if !final {
+				more = true
+			}
+		}
+	}
+
+	return nil, more
+}
+
+func (m *MuxListener) Addr() net.Addr {
+	if m.root == nil {
+		return nil
+	}
+
+	return m.root.Addr()
+}
+
+// Close stop accepting, close all the virtual listeners and the http servers, then wait the routing connections
+func (m *MuxListener) Close() error {
+	var err error
+	m.closeOnce.Do(func() {
+		close(m.done)
+		if m.root != nil {
+			err = m.root.Close()
+		}
+		for _, srv := range m.servers {
+			srv.Close()
+		}
+		for _, l := range m.routes {
+			l.Close()
+		}
+		m.wait.Wait()
+	})
+
+	return err
+}
+
+// virtualListener is the listener of the connections routed by MuxListener
+type virtualListener struct {
+	m       *MuxListener
+	matcher Matcher
+	conns   chan net.Conn
+	done    chan struct{}
+	once    sync.Once
+}
+
+func (l *virtualListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, Err_Listener_Closed
+	case <-l.m.done:
+		return nil, Err_Listener_Closed
+	}
+}
+
+// Close the virtual listener only, the connections routed to it are closed
+func (l *virtualListener) Close() error {
+	l.once.Do(func() {
+		close(l.done)
+	})
+	return nil
+}
+
+func (l *virtualListener) Addr() net.Addr {
+	if addr := l.m.Addr(); addr != nil {
+		return addr
+	}
+
+	return &net.TCPAddr{}
+}
+
+// peekedConn replay the bytes read by the matchers before reading the connection
+type peekedConn struct {
+	net.Conn
+	head []byte
+}
+
+func (c *peekedConn) Read(b []byte) (int, error) {
+	if len(c.head) > 0 {
+		n := copy(b, c.head)
+		c.head = c.head[n:]
+		return n, nil
+	}
+
+	return c.Conn.Read(b)
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kovey/network-go/v2/client"
+	"github.com/kovey/network-go/v2/connection"
+)
+
+type echoBody struct{}
+
+func (echoBody) Connect(*connection.Connection) error { return nil }
+func (echoBody) Receive(ctx *Context) error {
+	return ctx.Conn.Write(connection.NewPacket(ctx.Data.Body, ctx.Conn.Header()).Bytes())
+}
+func (echoBody) Close(*connection.Connection) error { return nil }
+
+func TestMatchers(t *testing.T) {
+	upgrade := "GET /ws HTTP/1.1\r\nHost: x\r\nUpgrade: WebSocket\r\n\r\n"
+	tests := []struct {
+		name    string
+		matcher Matcher
+		head    string
+		expect  Match
+	}{
+		{"any", MatchAny(), "", Match_Yes},
+		{"http", MatchHTTP(), "POST /x HTTP/1.1\r\n", Match_Yes},
+		{"http partial", MatchHTTP(), "OPT", Match_More},
+		{"http raw", MatchHTTP(), "\x00\x00\x00\x05hello", Match_No},
+		{"websocket", MatchWebSocket(), upgrade, Match_Yes},
+		{"websocket headers pending", MatchWebSocket(), "GET /ws HTTP/1.1\r\nUpgrade: websocket\r\n", Match_More},
+		{"websocket plain get", MatchWebSocket(), "GET / HTTP/1.1\r\nHost: x\r\n\r\n", Match_No},
+		{"websocket post", MatchWebSocket(), "POST / HTTP/1.1\r\n", Match_No},
+		{"tls", MatchTLS(), "\x16\x03\x01\x02\x00", Match_Yes},
+		{"tls partial", MatchTLS(), "\x16", Match_More},
+		{"tls raw", MatchTLS(), "\x00\x00", Match_No},
+		{"prefix", MatchPrefix([]byte("KO"), []byte("KX")), "KX1", Match_Yes},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.matcher([]byte(tt.head)); got != tt.expect {
+				t.Fatal(got)
+			}
+		})
+	}
+}
+
+// muxServe route websocket, http, tls and the raw framed connections of the echo server on one port
+func muxServe(t *testing.T) (*MuxListener, net.Listener, int) {
+	t.Helper()
+	m := NewMuxListener().WithPeekTimeout(200 * time.Millisecond)
+	m.HandleHTTP(MatchWebSocket(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Upgrade", "websocket")
+		w.WriteHeader(http.StatusSwitchingProtocols)
+	}))
+	m.HandleHTTP(MatchHTTP(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	tlsListener := m.Match(MatchTLS())
+	service := m.Service(MatchAny(), 10)
+	if err := m.Listen("127.0.0.1", 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Close() })
+
+	port := m.Addr().(*net.TCPAddr).Port
+	serveTest(t, NewServer("127.0.0.1", port).WithService(service).WithHandler(echoBody{}))
+	return m, tlsListener, port
+}
+
+func TestMuxListener(t *testing.T) {
+	m, tlsListener, port := muxServe(t)
+	address := m.Addr().String()
+
+	tests := []struct {
+		name  string
+		route func(t *testing.T)
+	}{
+		{"http", func(t *testing.T) {
+			resp, err := http.Get("http://" + address + "/health")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if body, _ := io.ReadAll(resp.Body); string(body) != "ok" {
+				t.Fatal(string(body))
+			}
+		}},
+		{"websocket", func(t *testing.T) {
+			conn, err := net.Dial("tcp", address)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			io.WriteString(conn, "GET /ws HTTP/1.1\r\nHost: x\r\nConnection: Upgrade\r\nUpgrade: WebSocket\r\n\r\n")
+			if line, _ := bufio.NewReader(conn).ReadString('\n'); !strings.Contains(line, "101") {
+				t.Fatal(line)
+			}
+		}},
+		{"tls", func(t *testing.T) {
+			go func() {
+				conn, err := tls.Dial("tcp", address, &tls.Config{InsecureSkipVerify: true})
+				if err == nil {
+					conn.Close()
+				}
+			}()
+			conn, err := tlsListener.Accept()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			head := make([]byte, 2)
+			if _, err := io.ReadFull(conn, head); err != nil || head[0] != 0x16 || head[1] != 0x03 {
+				t.Fatal(head, err)
+			}
+		}},
+		{"raw", func(t *testing.T) { rawEcho(t, port, 0) }},
+		// the raw client sends nothing in the peek timeout, it falls to MatchAny
+		{"raw silent", func(t *testing.T) { rawEcho(t, port, 300*time.Millisecond) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, tt.route)
+	}
+}
+
+func rawEcho(t *testing.T, port int, silent time.Duration) {
+	t.Helper()
+	tcp := client.NewTcp()
+	if err := tcp.Dial("127.0.0.1", port); err != nil {
+		t.Fatal(err)
+	}
+	defer tcp.Connection().Close()
+	time.Sleep(silent)
+	conn := tcp.Connection()
+	if err := conn.Write(connection.NewPacket([]byte("GET raw"), conn.Header()).Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := conn.Read(); err != nil || string(p.Body) != "GET raw" {
+		t.Fatal(p, err)
+	}
+}
+
+func TestMuxListenerNotMatched(t *testing.T) {
+	m := NewMuxListener().WithPeekTimeout(100 * time.Millisecond)
+	m.HandleHTTP(MatchHTTP(), http.NotFoundHandler())
+	if err := m.Listen("127.0.0.1", 0); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	conn, err := net.Dial("tcp", m.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte{0, 0, 0, 1, 'x'})
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, io.EOF) {
+		t.Fatal(err)
+	}
+}
+
+func TestMuxListenerClosed(t *testing.T) {
+	m := NewMuxListener()
+	l := m.Match(MatchAny())
+	if err := m.Listen("127.0.0.1", 0); err != nil {
+		t.Fatal(err)
+	}
+	m.Close()
+	if _, err := l.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/kovey/network-go/v2/connection"
+	"github.com/kovey/network-go/v2/logger"
+	"github.com/kovey/network-go/v2/push"
+)
+
+var Err_Outbox_Disabled = errors.New("outbox is disabled")
+var Err_Outbox_Full = errors.New("outbox is full")
+
+type message struct {
+	id       uint64
+	body     []byte
+	expireAt time.Time
+}
+
+type box struct {
+	messages []*message
+}
+
+func (b *box) prune(now time.Time) {
+	i := 0
+	for i < len(b.messages) && now.After(b.messages[i].expireAt) {
+		i++
+	}
+	b.messages = b.messages[i:]
+}
+
+// outbox keep the pushed messages of every bound key until they are acked or expired,
+// the pending messages are redelivered when the key is bound to a new connection.
+// The high 32 bits of the message ids are a random epoch of the outbox, the ids after a restart
+// are not dropped as duplicates by the clients
+type outbox struct {
+	window     time.Duration
+	maxPending int
+	boxes      map[any]*box
+	nextID     uint64
+	lastSweep  time.Time
+	locker     sync.Mutex
+}
+
+// WithOutbox enable reliable push, messages are kept for window and at most maxPending messages for one key,
+// the header of the connections must have the kind field
+func (s *Server) WithOutbox(window time.Duration, maxPending int) *Server {
+	s.outbox = &outbox{window: window, maxPending: maxPending, boxes: make(map[any]*box), nextID: epoch() << 32, lastSweep: time.Now()}
+	return s
+}
+
+// epoch the random non-zero 32 bits
+func epoch() uint64 {
+	var b [4]byte
+	for {
+		rand.Read(b[:])
+		if e := binary.BigEndian.Uint32(b[:]); e != 0 {
+			return uint64(e)
+		}
+	}
+}
+
+func (o *outbox) add(key any, body []byte) (*message, error) {
+	o.locker.Lock()
+	defer o.locker.Unlock()
+	now := time.Now()
+	if now.Sub(o.lastSweep) > o.window {
+		o.sweep(now)
+	}
+
+	b, ok := o.boxes[key]
+	if !ok {
+		b = &box{}
+		o.boxes[key] = b
+	}
+
+	b.prune(now)
+	if o.maxPending > 0 && len(b.messages) >= o.maxPending {
+		return nil, Err_Outbox_Full
+	}
+
+	o.nextID++
+	m := &message{id: o.nextID, body: push.Message(o.nextID, body), expireAt: now.Add(o.window)}
+	b.messages = append(b.messages, m)
+	return m, nil
+}
+
+func (o *outbox) sweep(now time.Time) {
+	o.lastSweep = now
+	for key, b := range o.boxes {
+		b.prune(now)
+		if len(b.messages) == 0 {
+			delete(o.boxes, key)
+		}
+	}
+}
+
+func (o *outbox) ack(key any, id uint64) {
+	o.locker.Lock()
+	defer o.locker.Unlock()
+	b, ok := o.boxes[key]
+	if !ok {
+		return
+	}
+
+	for i, m := range b.messages {
+		if m.id == id {
+			b.messages = append(b.messages[:i], b.messages[i+1:]...)
+			return
+		}
+	}
+}
+
+func (o *outbox) pending(key any) [][]byte {
+	o.locker.Lock()
+	defer o.locker.Unlock()
+	b, ok := o.boxes[key]
+	if !ok {
+		return nil
+	}
+
+	b.prune(time.Now())
+	bodies := make([][]byte, len(b.messages))
+	for i, m := range b.messages {
+		bodies[i] = m.body
+	}
+	return bodies
+}
+
+// Push send body to the connection bound to key and keep it until the client acks,
+// the message is delivered when the key is bound again if the client is offline
+func (s *Server) Push(key any, body []byte) (uint64, error) {
+	if s.outbox == nil {
+		return 0, Err_Outbox_Disabled
+	}
+
+	m, err := s.outbox.add(key, body)
+	if err != nil {
+		return 0, err
+	}
+
+	if conn, ok := s.Lookup(key); ok {
+		if err := conn.WriteFrame(connection.Kind_Push, m.body); err != nil {
+			if errors.Is(err, connection.Err_Body_Len_Overflow) {
+				s.outbox.ack(key, m.id)
+				return 0, err
+			}
+			conn.Logger().Erro("push message failure", logger.F("id", m.id), logger.Err(err))
+		}
+	}
+
+	return m.id, nil
+}
+
+func (s *Server) redeliver(key any, conn *connection.Connection) {
+	if s.outbox == nil {
+		return
+	}
+
+	for _, body := range s.outbox.pending(key) {
+		if err := conn.WriteFrame(connection.Kind_Push, body); err != nil {
+			conn.Logger().Erro("redeliver failure", logger.Err(err))
+			return
+		}
+	}
+}
+
+// handleAck consume the ack packet of the pushed message
+func (s *Server) handleAck(data *connection.Packet, conn *connection.Connection) bool {
+	if s.outbox == nil {
+		return false
+	}
+
+	t, id, _, ok := push.Decode(data)
+	if !ok || t != push.Type_Ack {
+		return false
+	}
+
+	if key, ok := connection.Attr[any](conn, bind_key); ok {
+		s.outbox.ack(key, id)
+	}
+	return true
+}
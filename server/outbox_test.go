@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kovey/network-go/v2/client"
+	"github.com/kovey/network-go/v2/connection"
+	"github.com/kovey/network-go/v2/push"
+)
+
+// kindService the memory service with the kind at offset 4 of the header
+func kindService() *TcpService {
+	return NewMemoryService(10).WithHeaderLen(5).WithKind(4)
+}
+
+// dialKind dial the memory server of kindService
+func dialKind(t *testing.T, host string, port int) *connection.Connection {
+	t.Helper()
+	tcp := client.NewMemory().WithHeaderLen(5).WithKind(4)
+	if err := tcp.Dial(host, port); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tcp.Connection().Close() })
+	return tcp.Connection()
+}
+
+// bindLatest bind the latest of count connections to key, the pending messages are written while the client reads
+func bindLatest(t *testing.T, s *Server, h *countHandler, count int32, key any) {
+	t.Helper()
+	waitFor(t, func() bool { return h.connects.Load() == count })
+	var latest *connection.Connection
+	for _, conn := range s.Conns() {
+		if latest == nil || conn.FD() > latest.FD() {
+			latest = conn
+		}
+	}
+	go s.Bind(key, latest)
+}
+
+func readPush(t *testing.T, conn *connection.Connection) (uint64, string) {
+	t.Helper()
+	p, err := conn.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	typ, id, payload, ok := push.Decode(p)
+	if !ok || typ != push.Type_Message {
+		t.Fatal(p.Kind(), p.Body)
+	}
+	return id, string(payload)
+}
+
+func TestOutboxRedeliver(t *testing.T) {
+	h := &countHandler{}
+	s := NewServer("outbox", 1).WithService(kindService()).WithHandler(h).WithOutbox(time.Minute, 10)
+	serveTest(t, s)
+
+	first := dialKind(t, "outbox", 1)
+	bindLatest(t, s, h, 1, "user")
+	waitFor(t, func() bool { _, ok := s.Lookup("user"); return ok })
+	go s.Push("user", []byte("hello"))
+	id, payload := readPush(t, first)
+	if payload != "hello" || id>>32 == 0 {
+		t.Fatal(id, payload)
+	}
+
+	// not acked, it is redelivered to the next connection
+	first.Close()
+	waitFor(t, func() bool { return h.closes.Load() == 1 })
+	second := dialKind(t, "outbox", 1)
+	bindLatest(t, s, h, 2, "user")
+	if again, payload := readPush(t, second); again != id || payload != "hello" {
+		t.Fatal(again, payload)
+	}
+
+	if err := second.WriteFrame(connection.Kind_Push, push.Ack(id)); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return len(s.outbox.pending("user")) == 0 })
+}
+
+func TestOutboxFull(t *testing.T) {
+	s := NewServer("outbox", 2).WithService(kindService()).WithOutbox(time.Minute, 2)
+	for i := 0; i < 2; i++ {
+		if _, err := s.Push("offline", []byte("x")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.Push("offline", []byte("x")); !errors.Is(err, Err_Outbox_Full) {
+		t.Fatal(err)
+	}
+	if _, err := NewServer("outbox", 2).Push("offline", nil); !errors.Is(err, Err_Outbox_Disabled) {
+		t.Fatal(err)
+	}
+}
+
+func TestOutboxEpoch(t *testing.T) {
+	a := NewServer("outbox", 3).WithOutbox(time.Minute, 0)
+	b := NewServer("outbox", 3).WithOutbox(time.Minute, 0)
+	first, _ := a.outbox.add("user", nil)
+	restarted, _ := b.outbox.add("user", nil)
+	if first.id == restarted.id || first.id>>32 == restarted.id>>32 {
+		t.Fatal(first.id, restarted.id)
+	}
+}
+
+func TestOutboxNoKind(t *testing.T) {
+	s := NewServer("outbox", 4).WithService(NewMemoryService(10)).WithOutbox(time.Minute, 0)
+	if err := s.ServeContext(context.Background()); !errors.Is(err, connection.Err_No_Kind) {
+		t.Fatal(err)
+	}
+}
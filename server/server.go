@@ -2,15 +2,21 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/kovey/debug-go/debug"
 	"github.com/kovey/debug-go/run"
 	"github.com/kovey/network-go/v2/connection"
+	"github.com/kovey/network-go/v2/logger"
 )
 
+var Err_Maintain = errors.New("server is into maintain")
+var Err_Pack_Empty = errors.New("pack is empty")
+var Err_Conn_Not_Found = errors.New("connection is not exists")
+
 type IService interface {
 	Listen(host string, port int) error
 	Accept() (*connection.Connection, error)
@@ -19,29 +25,61 @@ type IService interface {
 	IsClosed() bool
 }
 
+// IHeaderService is implemented by the service tells the header of its connections
+type IHeaderService interface {
+	Header() *connection.Header
+}
+
+// ILoggerService is implemented by the service takes the logger of the server
+type ILoggerService interface {
+	// InheritLogger use l for the service and its connections unless it has its own logger
+	InheritLogger(l logger.ILogger)
+}
+
 type IHandler interface {
 	Connect(*connection.Connection) error
 	Receive(*Context) error
 	Close(*connection.Connection) error
 }
 
+// ICloseHandler is implemented by the handler wants the reason of the closed connection,
+// CloseWith is called instead of Close, errors.Is(reason, connection.Err_Kicked) tells the kind of the reason
+type ICloseHandler interface {
+	CloseWith(conn *connection.Connection, reason error) error
+}
+
 type Server struct {
-	conns      sync.Map
-	service    IService
-	handler    IHandler
-	wait       sync.WaitGroup
-	isMaintain bool
-	host       string
-	port       int
-	OnSuccess  func(*Server)
+	conns         sync.Map // the admitted connections
+	pending       sync.Map // the connections in the handshake or authenticate
+	locker        sync.Mutex
+	isShutdown    bool
+	service       IService
+	handler       IHandler
+	wait          sync.WaitGroup
+	isMaintain    atomic.Bool
+	host          string
+	port          int
+	OnSuccess     func(*Server)
+	authenticator IAuthenticator
+	authTimeout   time.Duration
+	authReject    func(*connection.Connection, error) []byte
+	index         sync.Map
+	kick          func(*connection.Connection) []byte
+	dispatcher    IDispatcher
+	outbox        *outbox
+	groups        groups
+	sessions      *sessions
+	ctx           context.Context
+	logger        logger.ILogger
 }
 
 func NewServer(host string, port int) *Server {
-	return &Server{conns: sync.Map{}, wait: sync.WaitGroup{}, host: host, port: port, isMaintain: false}
+	return &Server{conns: sync.Map{}, wait: sync.WaitGroup{}, host: host, port: port, ctx: context.Background()}
 }
 
 func (s *Server) WithService(service IService) *Server {
 	s.service = service
+	s.inheritLogger()
 	return s
 }
 
@@ -50,6 +88,29 @@ func (s *Server) WithHandler(handler IHandler) *Server {
 	return s
 }
 
+// WithLogger the logger of the server, it is also the logger of the service and its connections
+// unless the service has its own logger by TcpService.WithLogger
+func (s *Server) WithLogger(l logger.ILogger) *Server {
+	s.logger = l
+	s.inheritLogger()
+	return s
+}
+
+func (s *Server) inheritLogger() {
+	if service, ok := s.service.(ILoggerService); ok && s.logger != nil {
+		service.InheritLogger(s.logger)
+	}
+}
+
+func (s *Server) log() logger.ILogger {
+	return logger.Or(s.logger)
+}
+
+func (s *Server) WithDispatcher(dispatcher IDispatcher) *Server {
+	s.dispatcher = dispatcher
+	return s
+}
+
 func (s *Server) listenAndServ() error {
 	return s.service.Listen(s.host, s.port)
 }
@@ -59,42 +120,53 @@ func (s *Server) connect(conn *connection.Connection) {
 		run.Panic(recover())
 	}()
 	if err := s.handler.Connect(conn); err != nil {
-		debug.Erro("connection[%d] on connect failure, error: %s", conn.FD(), err)
+		conn.Logger().Erro("on connect failure", logger.Err(err))
 	}
 }
 
 func (s *Server) loop() {
 	for {
 		if s.service.IsClosed() {
-			debug.Erro("service closed")
+			s.log().Erro("service closed")
 			break
 		}
 
 		conn, err := s.service.Accept()
 		if err != nil {
-			debug.Erro("accept error: %s", err)
+			s.log().Erro("accept failure", logger.Err(err))
 			continue
 		}
 
-		if s.isMaintain {
+		if s.IsMaintain() {
 			conn.Close()
-			debug.Erro("server is into maintain")
+			s.log().Erro("server is into maintain", logger.FD(conn.FD()))
+			continue
+		}
+
+		conn.WithContext(s.ctx)
+		if !s.hold(conn) {
+			s.service.Close()
+			conn.CloseWith(connection.Err_Shutdown)
 			continue
 		}
 
-		s.conns.Store(conn.FD(), conn)
-		s.wait.Add(1)
 		go s.handlerConn(conn)
 	}
-	debug.Warn("server main loop exit")
+	s.log().Warn("server main loop exit")
 }
 
+// Close the connection of fd, the close reason is the error ended the read of the connection
 func (s *Server) Close(fd uint64) error {
-	conn, ok := s.conns.Load(fd)
+	return s.CloseWith(fd, nil)
+}
+
+// CloseWith close the connection of fd for reason, the handler is told once
+// even when the connection is closed already, such as kicked
+func (s *Server) CloseWith(fd uint64, reason error) error {
+	conn, ok := s.conns.LoadAndDelete(fd)
 	if !ok {
 		return nil
 	}
-	s.conns.Delete(fd)
 	c, sure := conn.(*connection.Connection)
 	if !sure {
 		return nil
@@ -102,22 +174,25 @@ func (s *Server) Close(fd uint64) error {
 
 	s.service.Close()
 
-	err := c.Close()
-	if err != nil {
+	if err := c.CloseWith(reason); err != nil && err != connection.Err_Closed {
 		return err
 	}
 
+	if handler, ok := s.handler.(ICloseHandler); ok {
+		return handler.CloseWith(c, c.CloseReason())
+	}
+
 	return s.handler.Close(c)
 }
 
 func (s *Server) Send(pack []byte, fd int) error {
 	if pack == nil {
-		return fmt.Errorf("pack is empty")
+		return Err_Pack_Empty
 	}
 
-	conn, ok := s.conns.Load(fd)
+	conn, ok := s.conns.Load(uint64(fd))
 	if !ok {
-		return fmt.Errorf("connection[%d] is not exists", fd)
+		return fmt.Errorf("%w: fd[%d]", Err_Conn_Not_Found, fd)
 	}
 
 	c, sure := conn.(*connection.Connection)
@@ -130,46 +205,116 @@ func (s *Server) Send(pack []byte, fd int) error {
 
 func (s *Server) Shutdown() {
 	s.service.Shutdown()
+	s.locker.Lock()
+	s.isShutdown = true
+	s.pending.Range(func(_, conn any) bool {
+		conn.(*connection.Connection).CloseWith(connection.Err_Shutdown)
+		return true
+	})
+	s.locker.Unlock()
+
 	s.conns.Range(func(fd, conn interface{}) bool {
 		id, ok := fd.(uint64)
 		if !ok {
 			return true
 		}
 
-		s.Close(id)
+		s.CloseWith(id, connection.Err_Shutdown)
 		return true
 	})
 
 	s.wait.Wait()
-	s.handler = nil
+	if s.dispatcher != nil {
+		s.dispatcher.Shutdown()
+	}
+}
+
+// hold the accepted connection until it is admitted, it is closed by Shutdown
+func (s *Server) hold(conn *connection.Connection) bool {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	if s.isShutdown {
+		return false
+	}
+
+	s.wait.Add(1)
+	s.pending.Store(conn.FD(), conn)
+	return true
+}
+
+// admit handshake and authenticate the connection, only the admitted connection is stored,
+// then Send, Push, the groups and the admin endpoint can reach it
+func (s *Server) admit(conn *connection.Connection) error {
+	defer s.pending.Delete(conn.FD())
+	if err := conn.Handshake(); err != nil {
+		conn.Logger().Erro("handshake failure", logger.Err(err))
+		return err
+	}
+
+	go conn.ReadLoop()
+	if err := s.authenticate(conn); err != nil {
+		conn.Logger().Erro("authenticate failure", logger.Err(err))
+		s.reject(conn, err)
+		return err
+	}
+
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	if s.isShutdown {
+		return connection.Err_Shutdown
+	}
+
+	s.conns.Store(conn.FD(), conn)
+	return nil
 }
 
+// handlerConn the handler is called only for the admitted connection
 func (s *Server) handlerConn(conn *connection.Connection) {
-	s.connect(conn)
 	defer s.wait.Done()
 	defer func() {
 		run.Panic(recover())
 	}()
+	if err := s.admit(conn); err != nil {
+		s.service.Close()
+		conn.CloseWith(err)
+		return
+	}
+
 	defer s.Close(conn.FD())
-	go conn.ReadLoop()
+	s.open(conn)
+	s.connect(conn)
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
 	for {
-		if s.isMaintain {
-			debug.Erro("server is into maintain")
+		if s.IsMaintain() {
+			select {
+			case <-ticker.C:
+			case <-conn.Context().Done():
+				return
+			}
 			continue
 		}
 
 		select {
 		case pbuf, ok := <-conn.Packets():
 			if !ok {
+				if err := conn.Err(); err == connection.Err_Read_Timeout {
+					conn.Logger().Warn("read timeout")
+				}
 				return
 			}
 
-			s.handlerPacket(pbuf, conn)
+			if err := s.dispatch(pbuf, conn); err != nil {
+				conn.Logger().Erro("dispatch failure", logger.Err(err))
+				if err != Err_Dispatch_Dropped {
+					s.CloseWith(conn.FD(), err)
+					return
+				}
+			}
 		case now := <-ticker.C:
 			if conn.Expired(now) {
+				s.CloseWith(conn.FD(), connection.Err_Idle_Timeout)
 				return
 			}
 		}
@@ -177,34 +322,111 @@ func (s *Server) handlerConn(conn *connection.Connection) {
 	}
 }
 
+func (s *Server) dispatch(data *connection.Packet, conn *connection.Connection) error {
+	if s.handleAck(data, conn) || s.handleResume(data, conn) {
+		return nil
+	}
+
+	if s.dispatcher == nil {
+		s.handlerPacket(data, conn)
+		return nil
+	}
+
+	return s.dispatcher.Dispatch(conn, data, func() {
+		s.handlerPacket(data, conn)
+	})
+}
+
 func (s *Server) handlerPacket(data *connection.Packet, conn *connection.Connection) {
 	defer func() {
 		run.Panic(recover())
 	}()
-	context := NewContext(context.Background())
+	context := NewContext(conn.Context())
 	defer context.Drop()
 
 	context.Conn = conn
 	context.Data = data
 
 	if err := s.handler.Receive(context); err != nil {
-		debug.Erro("handler receive error: %s", err)
+		context.Logger().Erro("handler receive failure", logger.Err(err))
 	}
 }
 
 func (s *Server) ListenAndServ() {
-	err := s.listenAndServ()
-	if err != nil {
+	if err := s.ServeContext(context.Background()); err != nil {
 		panic(err)
 	}
+}
 
+// ServeContext listen and serve until ctx is cancelled, then the server is shutdown,
+// the contexts of the connections are derived from ctx
+func (s *Server) ServeContext(ctx context.Context) error {
+	if _, ok := s.service.(IEventService); ok && s.authenticator != nil {
+		return fmt.Errorf("%w: authenticator", Err_Event_Unsupported)
+	}
+	if err := s.checkKind(); err != nil {
+		return err
+	}
+	if err := s.listenAndServ(); err != nil {
+		return err
+	}
+
+	s.ctx = ctx
+	s.locker.Lock()
+	s.isShutdown = false
+	s.locker.Unlock()
 	if s.OnSuccess != nil {
 		s.OnSuccess(s)
 	}
 
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.Shutdown()
+		case <-done:
+		}
+	}()
+
+	if service, ok := s.service.(IEventService); ok {
+		return service.Serve(&events{s: s})
+	}
+
 	s.loop()
+	return nil
 }
 
+// checkKind reject the outbox and sessions when the header of the connections has no kind field
+func (s *Server) checkKind() error {
+	service, ok := s.service.(IHeaderService)
+	if !ok || service.Header().HasKind() {
+		return nil
+	}
+	if s.outbox != nil {
+		return fmt.Errorf("%w: outbox", connection.Err_No_Kind)
+	}
+	if s.sessions != nil {
+		return fmt.Errorf("%w: sessions", connection.Err_No_Kind)
+	}
+
+	return nil
+}
+
+// Maintain stop accepting new connections and pause the packets of the connected ones
 func (s *Server) Maintain() {
-	s.isMaintain = true
+	if !s.isMaintain.Swap(true) {
+		s.log().Warn("server is into maintain")
+	}
+}
+
+// EndMaintain accept new connections and resume the packets of the connected ones
+func (s *Server) EndMaintain() {
+	if s.isMaintain.Swap(false) {
+		s.log().Info("server is out of maintain")
+	}
+}
+
+func (s *Server) IsMaintain() bool {
+	return s.isMaintain.Load()
 }
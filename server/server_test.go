@@ -0,0 +1,181 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kovey/network-go/v2/client"
+	"github.com/kovey/network-go/v2/connection"
+	"github.com/kovey/network-go/v2/logger"
+)
+
+type countHandler struct {
+	connects atomic.Int32
+	closes   atomic.Int32
+}
+
+func (h *countHandler) Connect(*connection.Connection) error { h.connects.Add(1); return nil }
+func (h *countHandler) Receive(*Context) error               { return nil }
+func (h *countHandler) Close(*connection.Connection) error   { h.closes.Add(1); return nil }
+
+// serveTest serve s until the test ends
+func serveTest(t *testing.T, s *Server) {
+	t.Helper()
+	ready := make(chan struct{})
+	s.OnSuccess = func(*Server) { close(ready) }
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := s.ServeContext(ctx); err != nil {
+			t.Error(err)
+		}
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	select {
+	case <-ready:
+	case <-done:
+		t.FailNow()
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("condition is not met")
+}
+
+func TestServerLogger(t *testing.T) {
+	tests := []struct {
+		name   string
+		own    bool
+		first  bool
+		expect string
+	}{
+		{"server logger", false, false, "server"},
+		{"server logger first", false, true, "server"},
+		{"service logger", true, false, "service"},
+		{"service logger first", true, true, "service"},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buff bytes.Buffer
+			server := logger.NewSlog(slog.New(slog.NewTextHandler(&buff, nil)).With("logger", "server"))
+			service := NewMemoryService(10)
+			if tt.own {
+				service.WithLogger(logger.NewSlog(slog.New(slog.NewTextHandler(&buff, nil)).With("logger", "service")))
+			}
+
+			h := &countHandler{}
+			s := NewServer("logger", i+1).WithHandler(h)
+			if tt.first {
+				s.WithLogger(server).WithService(service)
+			} else {
+				s.WithService(service).WithLogger(server)
+			}
+			serveTest(t, s)
+
+			tcp := client.NewMemory()
+			if err := tcp.Dial("logger", i+1); err != nil {
+				t.Fatal(err)
+			}
+			defer tcp.Connection().Close()
+			waitFor(t, func() bool { return h.connects.Load() == 1 })
+			buff.Reset()
+			s.Conns()[0].Logger().Info("connected")
+			if !strings.Contains(buff.String(), "logger="+tt.expect) {
+				t.Fatal(buff.String())
+			}
+		})
+	}
+}
+
+// reasonHandler tell the connections and the close reasons
+type reasonHandler struct {
+	countHandler
+	conns   chan *connection.Connection
+	reasons chan error
+}
+
+func (h *reasonHandler) Connect(conn *connection.Connection) error {
+	h.conns <- conn
+	return nil
+}
+
+func (h *reasonHandler) Close(*connection.Connection) error {
+	panic("CloseWith is called instead")
+}
+
+func (h *reasonHandler) CloseWith(conn *connection.Connection, reason error) error {
+	h.reasons <- reason
+	return nil
+}
+
+// clientReason tell the close reasons of the client
+type clientReason struct {
+	reasons chan error
+}
+
+func (h *clientReason) Receive(*connection.Packet, *client.Client) error { return nil }
+func (h *clientReason) Idle(*client.Client) error                        { return nil }
+func (h *clientReason) Try(*client.Client) bool                          { return false }
+func (h *clientReason) Shutdown()                                        {}
+func (h *clientReason) Closed(c *client.Client, reason error)            { h.reasons <- reason }
+
+func TestCloseReason(t *testing.T) {
+	h := &reasonHandler{conns: make(chan *connection.Connection, 10), reasons: make(chan error, 10)}
+	s := NewServer("reason", 1).WithService(NewMemoryService(10).WithMaxLen(64).WithReadTimeout(300 * time.Millisecond)).WithHandler(h)
+	serveTest(t, s)
+
+	tests := []struct {
+		name   string
+		close  func(conn *connection.Connection, tcp *client.Tcp, cli *client.Client)
+		server connection.ErrorKind
+		client connection.ErrorKind
+	}{
+		{"kicked", func(conn *connection.Connection, _ *client.Tcp, _ *client.Client) { s.Kick(conn) }, connection.Err_Kicked, connection.Err_EOF},
+		{"oversize", func(_ *connection.Connection, tcp *client.Tcp, _ *client.Client) {
+			tcp.Connection().Write(connection.NewPacket(make([]byte, 200), tcp.Connection().Header()).Bytes())
+		}, connection.Err_Oversize, connection.Err_EOF},
+		{"idle", func(*connection.Connection, *client.Tcp, *client.Client) {}, connection.Err_Idle_Timeout, connection.Err_EOF},
+		{"client shutdown", func(_ *connection.Connection, _ *client.Tcp, cli *client.Client) { cli.Shutdown() }, connection.Err_EOF, connection.Err_Shutdown},
+		{"server shutdown", func(*connection.Connection, *client.Tcp, *client.Client) { go s.Shutdown() }, connection.Err_Shutdown, connection.Err_EOF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reasons := &clientReason{reasons: make(chan error, 1)}
+			tcp := client.NewMemory()
+			cli := client.NewClient().WithService(tcp).WithHandler(reasons)
+			if err := cli.Dial("reason", 1); err != nil {
+				t.Fatal(err)
+			}
+			go cli.Listen()
+			defer cli.Shutdown()
+
+			tt.close(<-h.conns, tcp, cli)
+			if reason := <-h.reasons; !errors.Is(reason, tt.server) {
+				t.Fatal(reason)
+			}
+			if reason := <-reasons.reasons; !errors.Is(reason, tt.client) {
+				t.Fatal(reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/kovey/network-go/v2/connection"
+	"github.com/kovey/network-go/v2/logger"
+	"github.com/kovey/network-go/v2/resume"
+)
+
+var Err_Session_Not_Found = errors.New("session is not found or expired")
+var Err_Session_Identity = errors.New("session belongs to another identity")
+var Err_Sessions_Full = errors.New("sessions are full")
+
+const (
+	session_key = "ko.network.session"
+	// resume_key the resume frame is accepted while the connection has it, the value is the held token
+	resume_key = "ko.network.resume"
+
+	default_max_sessions = 1 << 16
+)
+
+// session is the resumable state of a connection, it is kept for grace after the connection closed
+type session struct {
+	token    string
+	conn     *connection.Connection
+	identity any
+	attrs    map[string]any
+	groups   []string
+	bind     any
+	expireAt time.Time
+}
+
+type sessions struct {
+	grace     time.Duration
+	max       int
+	lastSweep time.Time
+	sessions  map[string]*session
+	locker    sync.Mutex
+}
+
+// WithSessions issue a resumable session token to every admitted connection,
+// the session is kept for grace after the connection closed.
+// The frames are carried by connection.Kind_Resume, so the header must have the kind field
+func (s *Server) WithSessions(grace time.Duration) *Server {
+	s.sessions = &sessions{grace: grace, max: default_max_sessions, sessions: make(map[string]*session)}
+	return s
+}
+
+// WithMaxSessions cap the live and kept sessions, no token is issued when full, default is 65536
+func (s *Server) WithMaxSessions(max int) *Server {
+	if s.sessions != nil && max > 0 {
+		s.sessions.max = max
+	}
+	return s
+}
+
+func newToken() (string, error) {
+	buff := make([]byte, 16)
+	if _, err := rand.Read(buff); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buff), nil
+}
+
+func (ss *sessions) sweep(now time.Time) {
+	for token, sess := range ss.sessions {
+		if sess.conn == nil && now.After(sess.expireAt) {
+			delete(ss.sessions, token)
+		}
+	}
+}
+
+// add the session, the expired ones are swept once per grace or when full
+func (ss *sessions) add(sess *session) error {
+	ss.locker.Lock()
+	defer ss.locker.Unlock()
+	now := time.Now()
+	if len(ss.sessions) >= ss.max || now.Sub(ss.lastSweep) >= ss.grace {
+		ss.sweep(now)
+		ss.lastSweep = now
+	}
+	if len(ss.sessions) >= ss.max {
+		return Err_Sessions_Full
+	}
+
+	ss.sessions[sess.token] = sess
+	return nil
+}
+
+// open issue the session of the admitted connection, then resume the token held by authenticate.
+// Without the authenticator the resume frame is accepted only as the first packet
+func (s *Server) open(conn *connection.Connection) {
+	if s.sessions == nil {
+		return
+	}
+
+	s.issue(conn)
+	token, held := connection.Attr[string](conn, resume_key)
+	if !held {
+		if s.authenticator == nil {
+			conn.Set(resume_key, "")
+		}
+		return
+	}
+
+	conn.Delete(resume_key)
+	s.resume(conn, token)
+}
+
+// issue create the session of the admitted connection and send the token to the client
+func (s *Server) issue(conn *connection.Connection) {
+	if _, ok := connection.Attr[string](conn, session_key); ok {
+		return
+	}
+
+	token, err := newToken()
+	if err != nil {
+		conn.Logger().Erro("create session token failure", logger.Err(err))
+		return
+	}
+
+	if err := s.sessions.add(&session{token: token, conn: conn}); err != nil {
+		conn.Logger().Warn("issue session failure", logger.Err(err))
+		return
+	}
+	s.attach(token, conn)
+}
+
+func (s *Server) attach(token string, conn *connection.Connection) {
+	conn.Set(session_key, token)
+	conn.OnClose(func(c *connection.Connection) {
+		s.detach(token, c)
+	})
+
+	if err := conn.WriteFrame(connection.Kind_Resume, resume.Issue(token)); err != nil {
+		conn.Logger().Erro("send session token failure", logger.Err(err))
+	}
+}
+
+// detach keep the state of the closed connection in the session until grace expired
+func (s *Server) detach(token string, conn *connection.Connection) {
+	s.sessions.locker.Lock()
+	defer s.sessions.locker.Unlock()
+	sess, ok := s.sessions.sessions[token]
+	if !ok || sess.conn != conn {
+		return
+	}
+
+	sess.identity = conn.Identity()
+	sess.attrs = make(map[string]any)
+	conn.Range(func(key string, value any) bool {
+		switch key {
+		case session_key, group_key:
+		case bind_key:
+			sess.bind = value
+		default:
+			sess.attrs[key] = value
+		}
+		return true
+	})
+	sess.groups = s.GroupsOf(conn)
+	sess.conn = nil
+	sess.expireAt = time.Now().Add(s.sessions.grace)
+}
+
+// Resume reattach the session of token to the new connection, the previous connection of the session is kicked.
+// The attributes, identity, groups and bound key are restored and the pending pushed messages are redelivered.
+// The session of another identity is not resumed when the connection is authenticated
+func (s *Server) Resume(conn *connection.Connection, token string) error {
+	if s.sessions == nil {
+		return Err_Session_Not_Found
+	}
+
+	s.sessions.locker.Lock()
+	sess, ok := s.sessions.sessions[token]
+	if !ok || (sess.conn == nil && time.Now().After(sess.expireAt)) {
+		s.sessions.locker.Unlock()
+		return Err_Session_Not_Found
+	}
+	prev := sess.conn
+	identity := sess.identity
+	if prev != nil {
+		identity = prev.Identity()
+	}
+	s.sessions.locker.Unlock()
+
+	if conn.Identity() != nil && !reflect.DeepEqual(identity, conn.Identity()) {
+		return Err_Session_Identity
+	}
+
+	if prev != nil && prev != conn {
+		s.Kick(prev)
+	}
+
+	s.sessions.locker.Lock()
+	if sess.conn != nil {
+		s.sessions.locker.Unlock()
+		return Err_Session_Not_Found
+	}
+	sess.conn = conn
+	if fresh, ok := connection.Attr[string](conn, session_key); ok && fresh != token {
+		delete(s.sessions.sessions, fresh)
+	}
+	s.sessions.locker.Unlock()
+
+	if sess.identity != nil {
+		conn.WithIdentity(sess.identity)
+	}
+	for key, value := range sess.attrs {
+		conn.Set(key, value)
+	}
+	for _, group := range sess.groups {
+		s.Join(group, conn)
+	}
+
+	s.attach(token, conn)
+	if sess.bind != nil {
+		s.Bind(sess.bind, conn)
+	}
+
+	return nil
+}
+
+// handleResume consume the resume frames, the token is resumed only when it is the first packet of the connection
+func (s *Server) handleResume(data *connection.Packet, conn *connection.Connection) bool {
+	if s.sessions == nil {
+		return false
+	}
+
+	_, first := connection.Attr[string](conn, resume_key)
+	if first {
+		conn.Delete(resume_key)
+	}
+
+	t, token, ok := resume.Decode(data)
+	if !ok {
+		return false
+	}
+
+	if t != resume.Type_Resume || !first {
+		conn.Logger().Warn("resume frame is dropped", logger.F("type", t))
+		return true
+	}
+
+	s.resume(conn, token)
+	return true
+}
+
+// holdResume hold the token of the resume frame sent before the authenticate packets,
+// it is resumed after the connection is admitted
+func (s *Server) holdResume(data *connection.Packet, conn *connection.Connection, first bool) bool {
+	if s.sessions == nil {
+		return false
+	}
+
+	t, token, ok := resume.Decode(data)
+	if !ok {
+		return false
+	}
+
+	if t == resume.Type_Resume && first {
+		conn.Set(resume_key, token)
+	}
+	return true
+}
+
+// resume the session of the token, the current token is sent again when failure
+func (s *Server) resume(conn *connection.Connection, token string) {
+	err := s.Resume(conn, token)
+	if err == nil {
+		return
+	}
+
+	conn.Logger().Erro("resume session failure", logger.Err(err))
+	if current, ok := connection.Attr[string](conn, session_key); ok {
+		if err := conn.WriteFrame(connection.Kind_Resume, resume.Issue(current)); err != nil {
+			conn.Logger().Erro("send session token failure", logger.Err(err))
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kovey/network-go/v2/connection"
+	"github.com/kovey/network-go/v2/resume"
+)
+
+// receiveHandler count the packets passed to the handler
+type receiveHandler struct {
+	countHandler
+	receives atomic.Int32
+}
+
+func (h *receiveHandler) Receive(*Context) error { h.receives.Add(1); return nil }
+
+// nameAuth the body of the first packet is the identity
+type nameAuth struct{}
+
+func (nameAuth) Authenticate(ctx *Context) (any, error) {
+	return string(ctx.Data.Body), nil
+}
+
+func readIssue(t *testing.T, conn *connection.Connection) string {
+	t.Helper()
+	p, err := conn.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	typ, token, ok := resume.Decode(p)
+	if !ok || typ != resume.Type_Issue {
+		t.Fatal(p.Kind(), p.Body)
+	}
+	return token
+}
+
+func writeBody(t *testing.T, conn *connection.Connection, body string) {
+	t.Helper()
+	if err := conn.WriteBody([]byte(body)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// leave set the state of the only connection of s, then close it
+func leave(t *testing.T, s *Server, h *receiveHandler, conn *connection.Connection) {
+	t.Helper()
+	waitFor(t, func() bool { return h.connects.Load() == 1 })
+	server := s.Conns()[0]
+	server.Set("role", "admin")
+	s.Join("room", server)
+	conn.Close()
+	waitFor(t, func() bool { return h.closes.Load() == 1 })
+}
+
+func resumed(s *Server) bool {
+	for _, conn := range s.Conns() {
+		if role, _ := connection.Attr[string](conn, "role"); role == "admin" {
+			return len(s.Members("room")) == 1
+		}
+	}
+	return false
+}
+
+func TestSessionResume(t *testing.T) {
+	tests := []struct {
+		name    string
+		late    bool
+		bad     bool
+		resumed bool
+	}{
+		{"resumed", false, false, true},
+		{"bad token", false, true, false},
+		{"not first packet", true, false, false},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &receiveHandler{}
+			s := NewServer("session", i+1).WithService(kindService()).WithHandler(h).WithSessions(time.Minute)
+			serveTest(t, s)
+
+			first := dialKind(t, "session", i+1)
+			token := readIssue(t, first)
+			leave(t, s, h, first)
+
+			second := dialKind(t, "session", i+1)
+			fresh := readIssue(t, second)
+			if fresh == token {
+				t.Fatal(fresh)
+			}
+			if tt.late {
+				writeBody(t, second, "data")
+			}
+			if tt.bad {
+				token = "bad"
+			}
+			if err := second.WriteFrame(connection.Kind_Resume, resume.Resume(token)); err != nil {
+				t.Fatal(err)
+			}
+
+			switch {
+			case tt.late:
+				// the late resume frame is dropped, it is not passed to the handler
+				writeBody(t, second, "data")
+				waitFor(t, func() bool { return h.receives.Load() == 2 })
+			case tt.resumed:
+				if got := readIssue(t, second); got != token {
+					t.Fatal(got)
+				}
+			default:
+				if got := readIssue(t, second); got != fresh {
+					t.Fatal(got)
+				}
+			}
+			if got := resumed(s); got != tt.resumed {
+				t.Fatal(got)
+			}
+		})
+	}
+}
+
+func TestSessionAuth(t *testing.T) {
+	tests := []struct {
+		name     string
+		identity string
+		resumed  bool
+	}{
+		{"same identity", "alice", true},
+		{"other identity", "bob", false},
+		{"token only", "", false},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &receiveHandler{}
+			port := len(tests) + i + 1
+			s := NewServer("session", port).WithService(kindService()).WithHandler(h).WithSessions(time.Minute).
+				WithAuthenticator(nameAuth{}, 100*time.Millisecond)
+			serveTest(t, s)
+
+			first := dialKind(t, "session", port)
+			writeBody(t, first, "alice")
+			token := readIssue(t, first)
+			leave(t, s, h, first)
+
+			second := dialKind(t, "session", port)
+			if err := second.WriteFrame(connection.Kind_Resume, resume.Resume(token)); err != nil {
+				t.Fatal(err)
+			}
+			if tt.identity == "" {
+				// the token is not a credential, the connection is closed by the authenticate timeout
+				if _, err := second.Read(); !errors.Is(err, io.EOF) {
+					t.Fatal(err)
+				}
+				if h.connects.Load() != 1 || len(s.sessions.sessions) != 1 {
+					t.Fatal(h.connects.Load(), len(s.sessions.sessions))
+				}
+				return
+			}
+
+			writeBody(t, second, tt.identity)
+			fresh := readIssue(t, second)
+			want := fresh
+			if tt.resumed {
+				want = token
+			}
+			if got := readIssue(t, second); got != want {
+				t.Fatal(got)
+			}
+			if got := resumed(s); got != tt.resumed {
+				t.Fatal(got)
+			}
+		})
+	}
+}
+
+func TestSessionFull(t *testing.T) {
+	h := &receiveHandler{}
+	s := NewServer("session", 7).WithService(kindService()).WithHandler(h).WithSessions(time.Minute).WithMaxSessions(1)
+	serveTest(t, s)
+
+	first := dialKind(t, "session", 7)
+	readIssue(t, first)
+	dialKind(t, "session", 7)
+	waitFor(t, func() bool { return h.connects.Load() == 2 })
+	if len(s.sessions.sessions) != 1 {
+		t.Fatal(len(s.sessions.sessions))
+	}
+}
+
+func TestSessionNoKind(t *testing.T) {
+	s := NewServer("session", 8).WithService(NewMemoryService(10)).WithSessions(time.Minute)
+	if err := s.ServeContext(context.Background()); !errors.Is(err, connection.Err_No_Kind) {
+		t.Fatal(err)
+	}
+}
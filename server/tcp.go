@@ -1,30 +1,54 @@
 package server
 
 import (
+	"crypto/tls"
 	"encoding/binary"
 	"fmt"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/kovey/debug-go/debug"
 	"github.com/kovey/network-go/v2/connection"
+	"github.com/kovey/network-go/v2/logger"
+	"github.com/kovey/network-go/v2/memnet"
 )
 
 type TcpService struct {
-	connMax     int
-	connCount   int
-	curFD       uint64
-	listener    net.Listener
-	locker      sync.Mutex
-	isClosed    bool
-	maxLen      int
-	header      *connection.Header
-	maxIdleTime time.Duration
+	connMax          int
+	connCount        int
+	curFD            uint64
+	listener         net.Listener
+	locker           sync.Mutex
+	isClosed         atomic.Bool
+	maxLen           int
+	header           *connection.Header
+	maxIdleTime      time.Duration
+	cipher           connection.CipherSuite
+	rotateEvery      uint64
+	psk              []byte
+	zeroCopy         int
+	buffLen          int
+	shrinkAfter      time.Duration
+	readTimeout      time.Duration
+	writeTimeout     time.Duration
+	handshakeTimeout time.Duration
+	logger           logger.ILogger
+	inherited        bool
+	sampleBurst      int
+	sampleInterval   time.Duration
+	tap              connection.ITap
+	listen           func(address string) (net.Listener, error)
+	tls              *tls.Config
+	checksumAction   connection.ChecksumAction
+	checksumHandler  connection.ChecksumHandler
+	versions         []uint16
+	features         connection.Feature
+	required         connection.Feature
 }
 
 func NewTcpService(connMax int) *TcpService {
-	return &TcpService{connMax: connMax, locker: sync.Mutex{}, header: connection.NewHeader()}
+	return &TcpService{connMax: connMax, locker: sync.Mutex{}, header: connection.NewHeader(), maxLen: 8192, buffLen: 1024, shrinkAfter: 30 * time.Second}
 }
 
 func (c *TcpService) WithMaxIdleTime(maxIdleTime time.Duration) *TcpService {
@@ -47,6 +71,79 @@ func (c *TcpService) WithMaxLen(maxLen int) *TcpService {
 	return c
 }
 
+// WithReadBuffLen the read buffer starts with length and grows up to maxLen when a larger packet arrives
+func (c *TcpService) WithReadBuffLen(length int) *TcpService {
+	c.buffLen = length
+	return c
+}
+
+// WithShrinkAfter the grown read buffer shrinks back when no data arrives in idle
+func (c *TcpService) WithShrinkAfter(idle time.Duration) *TcpService {
+	c.shrinkAfter = idle
+	return c
+}
+
+func (c *TcpService) WithZeroCopy(threshold int) *TcpService {
+	c.zeroCopy = threshold
+	return c
+}
+
+// WithReadTimeout the connection is closed when no data arrives in timeout
+func (c *TcpService) WithReadTimeout(timeout time.Duration) *TcpService {
+	c.readTimeout = timeout
+	return c
+}
+
+// WithWriteTimeout the write to a slow peer fails after timeout
+func (c *TcpService) WithWriteTimeout(timeout time.Duration) *TcpService {
+	c.writeTimeout = timeout
+	return c
+}
+
+// WithHandshakeTimeout the secure handshake fails after timeout
+func (c *TcpService) WithHandshakeTimeout(timeout time.Duration) *TcpService {
+	c.handshakeTimeout = timeout
+	return c
+}
+
+// WithLogger the logger of the service and the accepted connections, it overrides the logger of the server
+func (c *TcpService) WithLogger(l logger.ILogger) *TcpService {
+	c.logger = l
+	c.inherited = false
+	return c
+}
+
+// InheritLogger use the logger of the server unless WithLogger is set
+func (c *TcpService) InheritLogger(l logger.ILogger) {
+	if c.logger == nil || c.inherited {
+		c.logger = l
+		c.inherited = true
+	}
+}
+
+// WithLogSampling every accepted connection writes at most burst records in every interval
+func (c *TcpService) WithLogSampling(burst int, interval time.Duration) *TcpService {
+	c.sampleBurst = burst
+	c.sampleInterval = interval
+	return c
+}
+
+// WithTap observe the packets of every accepted connection, e.g. capture.Recorder
+func (c *TcpService) WithTap(tap connection.ITap) *TcpService {
+	c.tap = tap
+	return c
+}
+
+// WithTLS serve tls on the listener, the tls handshake is done on the first read of the connection
+func (c *TcpService) WithTLS(cfg *tls.Config) *TcpService {
+	c.tls = cfg
+	return c
+}
+
+func (c *TcpService) log() logger.ILogger {
+	return logger.Or(c.logger)
+}
+
 func (c *TcpService) WithHeaderLen(length int) *TcpService {
 	c.header.WithHeaderLen(length)
 	return c
@@ -57,35 +154,187 @@ func (c *TcpService) WithBodyLenOffset(offset int) *TcpService {
 	return c
 }
 
+// WithSchema the named fields of the header, Packet.Field decodes them
+func (c *TcpService) WithSchema(schema *connection.Schema) *TcpService {
+	c.header.WithSchema(schema)
+	return c
+}
+
+// WithChecksum the checksum of the body is written at offset of the header and verified by the read
+func (c *TcpService) WithChecksum(t connection.ChecksumType, offset int) *TcpService {
+	c.header.WithChecksum(t, offset)
+	return c
+}
+
+// WithKind the byte at offset of the header tells the stream, mux, push and resume frames from the application packets
+func (c *TcpService) WithKind(offset int) *TcpService {
+	c.header.WithKind(offset)
+	return c
+}
+
+// Header the header of the connections
+func (c *TcpService) Header() *connection.Header {
+	return c.header
+}
+
+// WithChecksumAction what the read does with the packet whose checksum mismatches, handler is used by connection.Checksum_Handler
+func (c *TcpService) WithChecksumAction(action connection.ChecksumAction, handler connection.ChecksumHandler) *TcpService {
+	c.checksumAction = action
+	c.checksumHandler = handler
+	return c
+}
+
+// WithVersions negotiate the protocol version with the client before the secure handshake,
+// the highest version supported by both is chosen, the client without negotiation is rejected
+func (c *TcpService) WithVersions(versions ...uint16) *TcpService {
+	c.versions = versions
+	return c
+}
+
+// WithFeatures the features negotiated with the versions, the client does not support all the required is rejected
+func (c *TcpService) WithFeatures(supported, required connection.Feature) *TcpService {
+	c.features = supported
+	c.required = required
+	return c
+}
+
+// WithSecure encrypt packet body after an ECDH handshake, the key is rotated every rotateEvery packets
+func (c *TcpService) WithSecure(suite connection.CipherSuite, rotateEvery uint64) *TcpService {
+	c.cipher = suite
+	c.rotateEvery = rotateEvery
+	return c
+}
+
+// WithPSK the pre-shared key of the secure channel authenticates the handshake, the clients must have the same key
+func (c *TcpService) WithPSK(psk []byte) *TcpService {
+	c.psk = psk
+	return c
+}
+
+// settings is shown by the admin endpoint
+func (t *TcpService) settings() map[string]any {
+	settings := map[string]any{
+		"conn_max": t.connMax, "max_len": t.maxLen, "read_buff_len": t.buffLen, "zero_copy": t.zeroCopy,
+		"max_idle_time": t.maxIdleTime.String(), "shrink_after": t.shrinkAfter.String(),
+		"read_timeout": t.readTimeout.String(), "write_timeout": t.writeTimeout.String(), "handshake_timeout": t.handshakeTimeout.String(),
+	}
+	if t.cipher != 0 {
+		settings["cipher"] = int(t.cipher)
+		settings["rotate_every"] = t.rotateEvery
+		settings["psk"] = len(t.psk) > 0
+	}
+	if t.tls != nil {
+		settings["tls"] = true
+	}
+	if len(t.versions) > 0 {
+		settings["versions"] = t.versions
+		settings["features"] = t.features.String()
+		settings["required_features"] = t.required.String()
+	}
+	if t.sampleBurst > 0 {
+		settings["log_sample_burst"] = t.sampleBurst
+		settings["log_sample_interval"] = t.sampleInterval.String()
+	}
+
+	return settings
+}
+
 func (t *TcpService) IsClosed() bool {
-	return t.isClosed
+	return t.isClosed.Load()
+}
+
+// WithListener serve the connections accepted by listener instead of listening on the address,
+// e.g. the virtual listener of MuxListener
+func (t *TcpService) WithListener(listener net.Listener) *TcpService {
+	t.listen = func(string) (net.Listener, error) {
+		return listener, nil
+	}
+	return t
+}
+
+// NewMemoryService serve the connections dialed by client.NewMemory in the same process, it is for tests
+func NewMemoryService(connMax int) *TcpService {
+	t := NewTcpService(connMax)
+	t.listen = func(address string) (net.Listener, error) {
+		return memnet.Listen(address)
+	}
+	return t
 }
 
 func (t *TcpService) Listen(host string, port int) error {
-	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", host, port))
+	if err := t.header.Validate(); err != nil {
+		return err
+	}
+	if t.maxLen <= t.header.HeaderLen() {
+		return fmt.Errorf("%w: max length(%d) <= header length(%d)", connection.Err_Invalid_Header, t.maxLen, t.header.HeaderLen())
+	}
+
+	listen := t.listen
+	if listen == nil {
+		listen = func(address string) (net.Listener, error) {
+			return net.Listen("tcp", address)
+		}
+	}
+
+	listener, err := listen(fmt.Sprintf("%s:%d", host, port))
 	if err != nil {
 		return err
 	}
+	if t.tls != nil {
+		listener = tls.NewListener(listener, t.tls)
+	}
 
-	debug.Info("server listen on %s:%d", host, port)
+	t.log().Info("server listen", logger.F("host", host), logger.F("port", port))
 
 	t.listener = listener
 	return nil
 }
 
 func (t *TcpService) Accept() (*connection.Connection, error) {
-	if t.connCount > t.connMax {
-		return nil, fmt.Errorf("connection is reach max[%d]", t.connMax)
+	_, c, err := t.accept()
+	return c, err
+}
+
+func (t *TcpService) accept() (net.Conn, *connection.Connection, error) {
+	t.locker.Lock()
+	count := t.connCount
+	t.locker.Unlock()
+	if count > t.connMax {
+		return nil, nil, fmt.Errorf("connection is reach max[%d]", t.connMax)
 	}
 
 	conn, err := t.listener.Accept()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
+	t.locker.Lock()
 	t.connCount++
+	t.locker.Unlock()
 	t.curFD++
-	return connection.NewConnectionBy(t.header, t.curFD, conn).WithMaxLen(t.maxLen).WithMaxIdleTime(t.maxIdleTime), nil
+	c := connection.NewConnectionBy(t.header, t.curFD, conn).WithMaxLen(t.maxLen).WithMaxIdleTime(t.maxIdleTime).WithZeroCopy(t.zeroCopy).WithReadBuffLen(t.buffLen).WithShrinkAfter(t.shrinkAfter)
+	c.WithReadTimeout(t.readTimeout).WithWriteTimeout(t.writeTimeout).WithHandshakeTimeout(t.handshakeTimeout)
+	if t.logger != nil || t.sampleBurst > 0 {
+		var l logger.ILogger = t.log()
+		if t.sampleBurst > 0 {
+			l = logger.NewSampler(l, t.sampleBurst, t.sampleInterval)
+		}
+		c.WithLogger(l)
+	}
+	if t.tap != nil {
+		c.WithTap(t.tap)
+	}
+	if t.checksumAction != connection.Checksum_Close || t.checksumHandler != nil {
+		c.WithChecksumAction(t.checksumAction, t.checksumHandler)
+	}
+	if len(t.versions) > 0 {
+		c.WithNegotiator(connection.NewNegotiator(false, t.versions...).WithFeatures(t.features, t.required))
+	}
+	if t.cipher != 0 {
+		c.WithSecure(connection.NewSecure(t.cipher, false).WithRotateEvery(t.rotateEvery).WithPSK(t.psk))
+	}
+
+	return conn, c, nil
 }
 
 func (t *TcpService) Close() {
@@ -95,6 +344,6 @@ func (t *TcpService) Close() {
 }
 
 func (t *TcpService) Shutdown() {
-	t.isClosed = true
+	t.isClosed.Store(true)
 	t.listener.Close()
 }
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kovey/network-go/v2/connection"
+)
+
+var Err_Version_Not_Routed = connection.NewError(connection.Err_Protocol, errors.New("no handler for the protocol version"))
+
+// VersionRouter is the handler routes the connections to the handlers of their negotiated versions,
+// the default handler serves the versions without their own handler
+type VersionRouter struct {
+	handlers map[uint16]IHandler
+	fallback IHandler
+}
+
+func NewVersionRouter() *VersionRouter {
+	return &VersionRouter{handlers: make(map[uint16]IHandler)}
+}
+
+// Handle the connections of version with handler
+func (r *VersionRouter) Handle(version uint16, handler IHandler) *VersionRouter {
+	r.handlers[version] = handler
+	return r
+}
+
+// WithDefault the handler of the versions without their own handler
+func (r *VersionRouter) WithDefault(handler IHandler) *VersionRouter {
+	r.fallback = handler
+	return r
+}
+
+func (r *VersionRouter) route(conn *connection.Connection) (IHandler, error) {
+	if handler, ok := r.handlers[conn.Version()]; ok {
+		return handler, nil
+	}
+	if r.fallback != nil {
+		return r.fallback, nil
+	}
+
+	return nil, fmt.Errorf("%w: %d", Err_Version_Not_Routed, conn.Version())
+}
+
+func (r *VersionRouter) Connect(conn *connection.Connection) error {
+	handler, err := r.route(conn)
+	if err != nil {
+		return err
+	}
+
+	return handler.Connect(conn)
+}
+
+func (r *VersionRouter) Receive(ctx *Context) error {
+	handler, err := r.route(ctx.Conn)
+	if err != nil {
+		return err
+	}
+
+	return handler.Receive(ctx)
+}
+
+func (r *VersionRouter) Close(conn *connection.Connection) error {
+	return r.CloseWith(conn, conn.CloseReason())
+}
+
+func (r *VersionRouter) CloseWith(conn *connection.Connection, reason error) error {
+	handler, err := r.route(conn)
+	if err != nil {
+		return err
+	}
+	if h, ok := handler.(ICloseHandler); ok {
+		return h.CloseWith(conn, reason)
+	}
+
+	return handler.Close(conn)
+}
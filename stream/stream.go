@@ -0,0 +1,525 @@
+package stream
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kovey/network-go/v2/connection"
+)
+
+var Err_Stream_Closed = errors.New("stream is closed")
+var Err_Stream_Aborted = errors.New("stream is aborted")
+var Err_Stream_Timeout = errors.New("stream timeout")
+var Err_Stream_Too_Large = errors.New("stream is too large")
+var Err_Stream_Sequence = errors.New("stream chunk out of sequence")
+var Err_Stream_Too_Many = errors.New("too many streams")
+var Err_Stream_Budget = errors.New("stream buffer budget exceeded")
+var Err_Stream_Chunk_Size = errors.New("no room for the chunk in a packet")
+
+// chunk frame is carried in the body of a packet of connection.Kind_Stream:
+// flags(1) | stream id(4) | seq(4) | payload
+// the body starts with magic(2) instead when the header has no kind field
+const (
+	chunk_magic      uint16 = 0x4b53
+	chunk_magic_len         = 2
+	chunk_header_len        = 9
+)
+
+const (
+	flag_open  byte = 1
+	flag_end   byte = 2
+	flag_abort byte = 4
+	flag_peer  byte = 8 // the frame is about the stream opened by the receiver
+)
+
+// IsChunk report whether the packet is a chunk frame, it is told by the kind of the header,
+// or by the magic of the body when the header has no kind field
+func IsChunk(packet *connection.Packet) bool {
+	_, ok := chunk(packet)
+	return ok
+}
+
+// chunk the chunk frame in the body of packet without the magic
+func chunk(packet *connection.Packet) ([]byte, bool) {
+	body := packet.Body
+	if packet.HasKind() {
+		return body, packet.Kind() == connection.Kind_Stream && len(body) >= chunk_header_len
+	}
+	if len(body) < chunk_magic_len+chunk_header_len || binary.BigEndian.Uint16(body) != chunk_magic {
+		return nil, false
+	}
+
+	return body[chunk_magic_len:], true
+}
+
+func encode(magic bool, flags byte, id, seq uint32, payload []byte) []byte {
+	offset := 0
+	if magic {
+		offset = chunk_magic_len
+	}
+
+	buff := make([]byte, offset+chunk_header_len+len(payload))
+	if magic {
+		binary.BigEndian.PutUint16(buff, chunk_magic)
+	}
+	buff[offset] = flags
+	binary.BigEndian.PutUint32(buff[offset+1:], id)
+	binary.BigEndian.PutUint32(buff[offset+5:], seq)
+	copy(buff[offset+chunk_header_len:], payload)
+	return buff
+}
+
+// Streams split large payloads into chunk frames over one connection and reassemble the chunks of the peer,
+// other packets interleave with the chunks
+type Streams struct {
+	conn       *connection.Connection
+	chunkSize  int
+	maxSize    int64
+	maxStreams int
+	budget     int64
+	buffered   atomic.Int64
+	timeout    time.Duration
+	nextID     atomic.Uint32
+	readers    map[uint32]*Reader
+	writers    map[uint32]*Writer
+	locker     sync.Mutex
+	onStream   func(*Reader)
+}
+
+func NewStreams(conn *connection.Connection) *Streams {
+	s := &Streams{conn: conn, chunkSize: 4096, maxSize: 1 << 20, maxStreams: 4, budget: 4 << 20, timeout: 30 * time.Second, readers: make(map[uint32]*Reader), writers: make(map[uint32]*Writer)}
+	conn.OnClose(func(*connection.Connection) {
+		s.abortAll(connection.Err_Closed)
+	})
+	return s
+}
+
+// WithChunkSize payload length of every chunk, it is ignored when size <= 0
+// and capped at the room left in a packet of the connection
+func (s *Streams) WithChunkSize(size int) *Streams {
+	if size > 0 {
+		s.chunkSize = size
+	}
+	return s
+}
+
+// ChunkSize payload length of the chunks, 0 when a packet of the connection has no room for the payload
+func (s *Streams) ChunkSize() int {
+	room := s.conn.MaxBodyLen() - chunk_header_len
+	if !s.conn.Header().HasKind() {
+		room -= chunk_magic_len
+	}
+
+	return max(min(s.chunkSize, room), 0)
+}
+
+// WithMaxSize max bytes of one received stream
+func (s *Streams) WithMaxSize(size int64) *Streams {
+	s.maxSize = size
+	return s
+}
+
+// WithMaxStreams max concurrent received streams
+func (s *Streams) WithMaxStreams(count int) *Streams {
+	s.maxStreams = count
+	return s
+}
+
+// WithBudget max bytes of all the received streams buffered and not read yet, the stream pushes it over is aborted
+func (s *Streams) WithBudget(size int64) *Streams {
+	s.budget = size
+	return s
+}
+
+// Buffered bytes of the received streams not read yet
+func (s *Streams) Buffered() int64 {
+	return s.buffered.Load()
+}
+
+// reserve n bytes of the budget
+func (s *Streams) reserve(n int64) bool {
+	if s.buffered.Add(n) <= s.budget || s.budget <= 0 {
+		return true
+	}
+
+	s.buffered.Add(-n)
+	return false
+}
+
+func (s *Streams) release(n int64) {
+	s.buffered.Add(-n)
+}
+
+// WithTimeout the received stream is aborted when no chunk arrives in timeout
+func (s *Streams) WithTimeout(timeout time.Duration) *Streams {
+	s.timeout = timeout
+	return s
+}
+
+// OnStream fn is called in a new goroutine for every stream opened by the peer
+func (s *Streams) OnStream(fn func(*Reader)) *Streams {
+	s.onStream = fn
+	return s
+}
+
+// Open a stream to the peer, Close the writer to finish the stream,
+// the writer fails with Err_Stream_Chunk_Size when a packet of the connection has no room for the payload
+func (s *Streams) Open() *Writer {
+	size := s.ChunkSize()
+	w := &Writer{s: s, id: s.nextID.Add(1), buff: make([]byte, 0, size)}
+	if size == 0 {
+		w.err = Err_Stream_Chunk_Size
+		return w
+	}
+	s.locker.Lock()
+	s.writers[w.id] = w
+	s.locker.Unlock()
+	return w
+}
+
+func (s *Streams) send(flags byte, id, seq uint32, payload []byte) error {
+	if !s.conn.Header().HasKind() {
+		return s.conn.WriteBody(encode(true, flags, id, seq, payload))
+	}
+
+	return s.conn.WriteFrame(connection.Kind_Stream, encode(false, flags, id, seq, payload))
+}
+
+// Handle consume the packet when it is a chunk frame, handled is false for other packets
+func (s *Streams) Handle(packet *connection.Packet) (handled bool, err error) {
+	body, ok := chunk(packet)
+	if !ok {
+		return false, nil
+	}
+
+	flags := body[0]
+	id := binary.BigEndian.Uint32(body[1:])
+	seq := binary.BigEndian.Uint32(body[5:])
+	payload := body[chunk_header_len:]
+	if flags&flag_peer != 0 {
+		s.locker.Lock()
+		w := s.writers[id]
+		s.locker.Unlock()
+		if w != nil && flags&flag_abort != 0 {
+			w.abort()
+		}
+		return true, nil
+	}
+
+	s.locker.Lock()
+	r, ok := s.readers[id]
+	if !ok {
+		if flags&flag_open == 0 {
+			s.locker.Unlock()
+			return true, nil
+		}
+		if len(s.readers) >= s.maxStreams {
+			s.locker.Unlock()
+			s.send(flag_abort|flag_peer, id, 0, nil)
+			return true, Err_Stream_Too_Many
+		}
+
+		r = newReader(s, id)
+		s.readers[id] = r
+		if s.onStream != nil {
+			go s.onStream(r)
+		}
+	}
+	s.locker.Unlock()
+
+	if flags&flag_abort != 0 {
+		r.abort(Err_Stream_Aborted)
+		return true, nil
+	}
+
+	if err := r.push(seq, payload, flags&flag_end != 0); err != nil {
+		s.send(flag_abort|flag_peer, id, 0, nil)
+		return true, err
+	}
+
+	return true, nil
+}
+
+func (s *Streams) remove(id uint32) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	delete(s.readers, id)
+}
+
+func (s *Streams) removeWriter(id uint32) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	delete(s.writers, id)
+}
+
+func (s *Streams) abortAll(err error) {
+	s.locker.Lock()
+	readers := make([]*Reader, 0, len(s.readers))
+	for _, r := range s.readers {
+		readers = append(readers, r)
+	}
+	writers := make([]*Writer, 0, len(s.writers))
+	for _, w := range s.writers {
+		writers = append(writers, w)
+	}
+	s.locker.Unlock()
+
+	for _, r := range readers {
+		r.abort(err)
+	}
+	for _, w := range writers {
+		w.abort()
+	}
+}
+
+// Writer split the written bytes into chunk frames
+type Writer struct {
+	s        *Streams
+	id       uint32
+	seq      uint32
+	buff     []byte
+	isClosed bool
+	err      error
+	locker   sync.Mutex
+}
+
+func (w *Writer) ID() uint32 {
+	return w.id
+}
+
+func (w *Writer) flags(end bool) byte {
+	var flags byte
+	if w.seq == 0 {
+		flags |= flag_open
+	}
+	if end {
+		flags |= flag_end
+	}
+
+	return flags
+}
+
+func (w *Writer) flush(end bool) error {
+	if err := w.s.send(w.flags(end), w.id, w.seq, w.buff); err != nil {
+		return err
+	}
+
+	w.seq++
+	w.buff = w.buff[:0]
+	return nil
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	w.locker.Lock()
+	defer w.locker.Unlock()
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.isClosed {
+		return 0, Err_Stream_Closed
+	}
+
+	written := 0
+	for len(p) > 0 {
+		n := copy(w.buff[len(w.buff):cap(w.buff)], p)
+		w.buff = w.buff[:len(w.buff)+n]
+		p = p[n:]
+		written += n
+		if len(w.buff) == cap(w.buff) {
+			if err := w.flush(false); err != nil {
+				return written, err
+			}
+		}
+	}
+
+	return written, nil
+}
+
+// Close send the rest bytes and finish the stream
+func (w *Writer) Close() error {
+	w.locker.Lock()
+	defer w.locker.Unlock()
+	if w.isClosed {
+		return nil
+	}
+
+	w.isClosed = true
+	w.s.removeWriter(w.id)
+	if w.err != nil {
+		return w.err
+	}
+	return w.flush(true)
+}
+
+// abort the stream aborted by the peer or the connection closed
+func (w *Writer) abort() {
+	w.locker.Lock()
+	defer w.locker.Unlock()
+	w.err = Err_Stream_Aborted
+	w.isClosed = true
+	w.s.removeWriter(w.id)
+}
+
+// Abort tell the peer to discard the stream
+func (w *Writer) Abort() error {
+	w.locker.Lock()
+	defer w.locker.Unlock()
+	if w.isClosed {
+		return nil
+	}
+
+	w.isClosed = true
+	w.s.removeWriter(w.id)
+	return w.s.send(flag_abort, w.id, w.seq, nil)
+}
+
+// Reader is the reassembled stream opened by the peer
+type Reader struct {
+	s        *Streams
+	id       uint32
+	seq      uint32
+	size     int64
+	buffered int64 // bytes of chunks reserved from the budget
+	chunks   [][]byte
+	isEnd    bool
+	err      error
+	cond     *sync.Cond
+	locker   sync.Mutex
+	timer    *time.Timer
+	discard  bool
+}
+
+func newReader(s *Streams, id uint32) *Reader {
+	r := &Reader{s: s, id: id}
+	r.cond = sync.NewCond(&r.locker)
+	if s.timeout > 0 {
+		r.timer = time.AfterFunc(s.timeout, func() {
+			r.abort(Err_Stream_Timeout)
+		})
+	}
+	return r
+}
+
+func (r *Reader) ID() uint32 {
+	return r.id
+}
+
+func (r *Reader) push(seq uint32, payload []byte, end bool) error {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	if r.err != nil || r.isEnd {
+		return nil
+	}
+
+	if seq != r.seq {
+		r.fail(Err_Stream_Sequence)
+		return Err_Stream_Sequence
+	}
+
+	r.seq++
+	r.size += int64(len(payload))
+	if r.s.maxSize > 0 && r.size > r.s.maxSize {
+		r.fail(Err_Stream_Too_Large)
+		return Err_Stream_Too_Large
+	}
+
+	if len(payload) > 0 && !r.discard {
+		if !r.s.reserve(int64(len(payload))) {
+			r.fail(Err_Stream_Budget)
+			return Err_Stream_Budget
+		}
+		r.chunks = append(r.chunks, append([]byte(nil), payload...))
+		r.buffered += int64(len(payload))
+	}
+
+	if r.timer != nil {
+		r.timer.Reset(r.s.timeout)
+	}
+
+	if end {
+		r.isEnd = true
+		r.finish()
+	}
+
+	r.cond.Broadcast()
+	return nil
+}
+
+// fail and finish must be called with locker held
+func (r *Reader) fail(err error) {
+	r.err = err
+	r.drop()
+	r.finish()
+	r.cond.Broadcast()
+}
+
+// drop the chunks and give their bytes back to the budget
+func (r *Reader) drop() {
+	r.chunks = nil
+	r.s.release(r.buffered)
+	r.buffered = 0
+}
+
+func (r *Reader) finish() {
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	r.s.remove(r.id)
+}
+
+func (r *Reader) abort(err error) {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	if r.err != nil || r.isEnd {
+		return
+	}
+
+	r.fail(err)
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	if r.discard {
+		return 0, Err_Stream_Closed
+	}
+
+	for len(r.chunks) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.isEnd {
+			return 0, io.EOF
+		}
+
+		r.cond.Wait()
+		if r.discard {
+			return 0, Err_Stream_Closed
+		}
+	}
+
+	n := copy(p, r.chunks[0])
+	r.buffered -= int64(n)
+	r.s.release(int64(n))
+	if n == len(r.chunks[0]) {
+		r.chunks = r.chunks[1:]
+	} else {
+		r.chunks[0] = r.chunks[0][n:]
+	}
+
+	return n, nil
+}
+
+// Close discard the rest chunks of the stream
+func (r *Reader) Close() error {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	r.discard = true
+	r.drop()
+	r.cond.Broadcast()
+	return nil
+}
@@ -0,0 +1,166 @@
+package stream
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/kovey/network-go/v2/connection"
+)
+
+// streamPair the connections of both sides of a pipe, the header has the kind at offset 4 when kind is true
+func streamPair(t *testing.T, kind bool) (*connection.Connection, *connection.Connection) {
+	t.Helper()
+	a, b := net.Pipe()
+	ca := connection.NewConnection(1, a)
+	cb := connection.NewConnection(2, b).WithMaxLen(1024)
+	if kind {
+		ca.WithHeaderLen(5).WithKind(4)
+		cb.WithHeaderLen(5).WithKind(4)
+	}
+	t.Cleanup(func() {
+		ca.Close()
+		cb.Close()
+	})
+	return ca, cb
+}
+
+// serve pass the packets of conn to s, the other packets are sent to others
+func serve(t *testing.T, conn *connection.Connection, s *Streams, others chan<- *connection.Packet, errs chan<- error) {
+	for {
+		p, err := conn.Read()
+		if err != nil {
+			return
+		}
+		handled, err := s.Handle(p)
+		if err != nil && errs != nil {
+			errs <- err
+		}
+		if !handled && others != nil {
+			others <- p
+		}
+	}
+}
+
+func TestStreamInterleave(t *testing.T) {
+	// the application bodies start with the magic of the chunk frame
+	tests := []struct {
+		name  string
+		kind  bool
+		other []byte
+	}{
+		{"kind", true, []byte{0x4b, 0x53, 1, 0, 0, 0, 1, 0, 0, 0, 0}},
+		{"magic", false, []byte("other")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ca, cb := streamPair(t, tt.kind)
+			sa := NewStreams(ca).WithChunkSize(500)
+			got := make(chan []byte, 1)
+			sb := NewStreams(cb).OnStream(func(r *Reader) {
+				data, err := io.ReadAll(r)
+				if err != nil {
+					t.Error(err)
+				}
+				got <- data
+			})
+
+			payload := bytes.Repeat([]byte("abcdefg"), 100000)
+			go func() {
+				w := sa.Open()
+				for i := 0; i < len(payload); i += 33333 {
+					w.Write(payload[i:min(i+33333, len(payload))])
+					ca.WriteBody(tt.other)
+				}
+				w.Close()
+			}()
+
+			others := make(chan *connection.Packet, 100)
+			go serve(t, cb, sb, others, nil)
+			if data := <-got; !bytes.Equal(data, payload) {
+				t.Fatal(len(data))
+			}
+			if len(others) != 22 {
+				t.Fatal(len(others))
+			}
+			if p := <-others; p.Kind() != connection.Kind_Data || !bytes.Equal(p.Body, tt.other) {
+				t.Fatal(p.Kind(), p.Body)
+			}
+			if sb.Buffered() != 0 {
+				t.Fatal(sb.Buffered())
+			}
+		})
+	}
+}
+
+func TestStreamBudget(t *testing.T) {
+	ca, cb := streamPair(t, true)
+	sa := NewStreams(ca).WithChunkSize(500)
+	opened := make(chan *Reader, 2)
+	sb := NewStreams(cb).WithBudget(1500).OnStream(func(r *Reader) {
+		opened <- r
+	})
+	errs := make(chan error, 4)
+	go serve(t, ca, sa, nil, nil)
+	go serve(t, cb, sb, nil, errs)
+
+	first := sa.Open()
+	first.Write(make([]byte, 1000))
+	r := <-opened
+	second := sa.Open()
+	second.Write(make([]byte, 1000))
+	if err := <-errs; !errors.Is(err, Err_Stream_Budget) {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(<-opened); !errors.Is(err, Err_Stream_Budget) {
+		t.Fatal(err)
+	}
+
+	// reading the first stream gives the bytes back
+	buff := make([]byte, 1000)
+	if n, err := io.ReadFull(r, buff); n != 1000 || err != nil {
+		t.Fatal(n, err)
+	}
+	if sb.Buffered() != 0 {
+		t.Fatal(sb.Buffered())
+	}
+}
+
+func TestStreamChunkSize(t *testing.T) {
+	int8Header := func(c *connection.Connection) {
+		c.WithHeaderLen(2).WithBodyLenType(connection.Len_Type_Int8).WithKind(1)
+	}
+	tests := []struct {
+		name   string
+		setup  func(c *connection.Connection)
+		size   int
+		expect int
+	}{
+		{"default", nil, 0, 4096},
+		{"negative", nil, -1, 4096},
+		{"max len", func(c *connection.Connection) { c.WithMaxLen(1024) }, 8192, 1024 - 4 - 2 - 9},
+		{"length type", int8Header, 200, 127 - 9},
+		{"no room", func(c *connection.Connection) { int8Header(c); c.WithMaxLen(11) }, 200, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := connection.NewConnection(1, nil)
+			if tt.setup != nil {
+				tt.setup(conn)
+			}
+			s := NewStreams(conn).WithChunkSize(tt.size)
+			if size := s.ChunkSize(); size != tt.expect {
+				t.Fatal(size)
+			}
+			if tt.expect == 0 {
+				if _, err := s.Open().Write([]byte("x")); !errors.Is(err, Err_Stream_Chunk_Size) {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+}